@@ -0,0 +1,289 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	routing "github.com/go-ozzo/ozzo-routing"
+	"github.com/go-ozzo/ozzo-routing/content"
+)
+
+//
+// Admin API, registered with RegisterAdminEndpoints
+//
+// Name	                 HTTP Verb	URI Path
+// Admin State	             GET	/service/admin/healthcheck
+// Pause Scheduler	         POST	/service/admin/healthcheck/pause
+// Resume Scheduler	         POST	/service/admin/healthcheck/resume
+// Force Run	             POST	/service/admin/healthcheck/run
+// Pause Check	             POST	/service/admin/healthcheck/checks/<name>/pause
+// Resume Check	             POST	/service/admin/healthcheck/checks/<name>/resume
+// Override Check	         POST	/service/admin/healthcheck/checks/<name>/override?result=failed&duration=5m
+// Clear Override	         DELETE	/service/admin/healthcheck/checks/<name>/override
+// Audit Log	             GET	/service/admin/actions
+//
+// and the fault injection routes in faults.go
+
+var (
+	DefaultAdminAuditSize = 100 // the number of admin actions kept
+)
+
+// AdminAuthFunc authenticates a request to the admin API, returning who made it.
+type AdminAuthFunc func(r *http.Request) (user string, ok bool)
+
+// BasicAuth authenticates admin requests against a map of user names to passwords.
+func BasicAuth(credentials map[string]string) AdminAuthFunc {
+	return func(r *http.Request) (string, bool) {
+		user, password, ok := r.BasicAuth()
+		if !ok {
+			return "", false
+		}
+		expected, found := credentials[user]
+		if subtle.ConstantTimeCompare([]byte(password), []byte(expected)) != 1 || !found {
+			return "", false
+		}
+		return user, true
+	}
+}
+
+// BearerTokenAuth authenticates admin requests against a map of tokens to the user they belong to.
+func BearerTokenAuth(tokens map[string]string) AdminAuthFunc {
+	return func(r *http.Request) (string, bool) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		for t, user := range tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+				return user, true
+			}
+		}
+		return "", false
+	}
+}
+
+// AdminAction is an audit record of a change made through the admin API.
+type AdminAction struct {
+	User      string    `json:"user"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target,omitempty"` // the check name, empty for the scheduler
+	Detail    string    `json:"detail,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// HealthCheckOverride forces the result of a check until it expires.
+type HealthCheckOverride struct {
+	Result  string    `json:"result"`
+	Until   time.Time `json:"until"`
+	SetBy   string    `json:"set_by"`
+	SetWhen time.Time `json:"set_when"`
+}
+
+// AdminState is the state of the health checks as changed through the admin API.
+type AdminState struct {
+	SchedulerPaused bool                           `json:"scheduler_paused"`
+	PausedChecks    []string                       `json:"paused_checks"`
+	Overrides       map[string]HealthCheckOverride `json:"overrides"`
+}
+
+// Stop scheduled health check runs without stopping the scheduler, RunHealthChecks still works.
+func (s *StandardEndpoints) PauseHealthChecks() {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.schedulerPaused = true
+}
+
+func (s *StandardEndpoints) ResumeHealthChecks() {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.schedulerPaused = false
+}
+
+// Skip the named check, it is reported as not_run until resumed.
+func (s *StandardEndpoints) PauseHealthCheck(name string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.pausedChecks[name] = true
+}
+
+func (s *StandardEndpoints) ResumeHealthCheck(name string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	delete(s.pausedChecks, name)
+}
+
+// Report the named check with the given result instead of its own until the duration has passed.
+// It applies to the last report right away, not only from the next run.
+func (s *StandardEndpoints) OverrideHealthCheck(name, result string, duration time.Duration, user string) error {
+	switch result {
+	case HealthResultNotRun, HealthResultRunning, HealthResultPassed, HealthResultFailed:
+	default:
+		return fmt.Errorf("unknown health result %q", result)
+	}
+	if duration <= 0 {
+		return fmt.Errorf("override duration must be positive")
+	}
+	now := time.Now().UTC()
+	s.locker.Lock()
+	s.overrides[name] = HealthCheckOverride{Result: result, Until: now.Add(duration), SetBy: user, SetWhen: now}
+	s.locker.Unlock()
+	s.publishHealthReport()
+	return nil
+}
+
+func (s *StandardEndpoints) ClearHealthCheckOverride(name string) {
+	s.locker.Lock()
+	delete(s.overrides, name)
+	s.locker.Unlock()
+	s.publishHealthReport()
+}
+
+func (s *StandardEndpoints) AdminState() AdminState {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	state := AdminState{SchedulerPaused: s.schedulerPaused, PausedChecks: []string{},
+		Overrides: map[string]HealthCheckOverride{}}
+	for name := range s.pausedChecks {
+		state.PausedChecks = append(state.PausedChecks, name)
+	}
+	sort.Strings(state.PausedChecks)
+	now := time.Now()
+	for name, o := range s.overrides {
+		if now.Before(o.Until) {
+			state.Overrides[name] = o
+		}
+	}
+	return state
+}
+
+// AdminActions returns the audit log of admin actions, oldest first.
+func (s *StandardEndpoints) AdminActions() []AdminAction {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return append([]AdminAction{}, s.adminActions...)
+}
+
+func (s *StandardEndpoints) recordAdminAction(action AdminAction) {
+	s.locker.Lock()
+	s.adminActions = append(s.adminActions, action)
+	if len(s.adminActions) > DefaultAdminAuditSize {
+		s.adminActions = s.adminActions[len(s.adminActions)-DefaultAdminAuditSize:]
+	}
+	s.locker.Unlock()
+	s.log("admin action", "user", action.User, "action", action.Action, "target", action.Target, "detail", action.Detail)
+	// the event log may be public, so who did it & the detail are only in the audit log
+	s.events.record(EventAdminAction, strings.TrimSpace(action.Action+" "+action.Target),
+		publicAdminAction{Action: action.Action, Target: action.Target})
+}
+
+// an admin action as recorded in the event log
+type publicAdminAction struct {
+	Action string `json:"action"`
+	Target string `json:"target,omitempty"`
+}
+
+// apply unexpired overrides to the results, must be called with the lock held
+func (s *StandardEndpoints) applyOverrides(results []HealthCheckResult, now time.Time) {
+	for i := range results {
+		if o, ok := s.overrides[results[i].Name]; ok {
+			if now.Before(o.Until) {
+				results[i].Result = o.Result
+			} else {
+				delete(s.overrides, results[i].Name)
+			}
+		}
+	}
+}
+
+const adminUserKey = "se4.admin.user"
+
+// Register the admin API under /admin in the group, every request is authenticated with auth.
+func (s *StandardEndpoints) RegisterAdminEndpoints(group *routing.RouteGroup, auth AdminAuthFunc) {
+	admin := group.Group("/admin", content.TypeNegotiator(routing.MIME_JSON), func(c *routing.Context) error {
+		user, ok := auth(c.Request)
+		if !ok {
+			c.Response.Header().Set("WWW-Authenticate", `Basic realm="se4 admin"`)
+			c.Abort()
+			return routing.NewHTTPError(http.StatusUnauthorized)
+		}
+		c.Set(adminUserKey, user)
+		return nil
+	})
+
+	// run the action, record who did it & respond with the resulting state
+	act := func(action string, fn func(c *routing.Context) (detail string, err error)) routing.Handler {
+		return func(c *routing.Context) error {
+			detail, err := fn(c)
+			if httpErr, ok := err.(routing.HTTPError); ok {
+				return httpErr
+			} else if err != nil {
+				return routing.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			s.recordAdminAction(AdminAction{User: c.Get(adminUserKey).(string), Action: action,
+				Target: c.Param("name"), Detail: detail, Timestamp: time.Now().UTC()})
+			return c.Write(s.AdminState())
+		}
+	}
+
+	admin.Get("/healthcheck", func(c *routing.Context) error {
+		return c.Write(s.AdminState())
+	})
+	admin.Post("/healthcheck/pause", act("pause", func(c *routing.Context) (string, error) {
+		s.PauseHealthChecks()
+		return "", nil
+	}))
+	admin.Post("/healthcheck/resume", act("resume", func(c *routing.Context) (string, error) {
+		s.ResumeHealthChecks()
+		return "", nil
+	}))
+	admin.Post("/healthcheck/run", act("run", func(c *routing.Context) (string, error) {
+		if err := s.RunHealthChecks(); err != nil {
+			return "", routing.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		return "", nil
+	}))
+	admin.Post("/healthcheck/checks/<name>/pause", act("pause", func(c *routing.Context) (string, error) {
+		s.PauseHealthCheck(c.Param("name"))
+		return "", nil
+	}))
+	admin.Post("/healthcheck/checks/<name>/resume", act("resume", func(c *routing.Context) (string, error) {
+		s.ResumeHealthCheck(c.Param("name"))
+		return "", nil
+	}))
+	admin.Post("/healthcheck/checks/<name>/override", act("override", func(c *routing.Context) (string, error) {
+		result := c.Query("result")
+		duration, err := time.ParseDuration(c.Query("duration"))
+		if err != nil {
+			return "", fmt.Errorf("invalid duration: %v", err)
+		}
+		detail := result + " for " + duration.String()
+		return detail, s.OverrideHealthCheck(c.Param("name"), result, duration, c.Get(adminUserKey).(string))
+	}))
+	admin.Delete("/healthcheck/checks/<name>/override", act("clear override", func(c *routing.Context) (string, error) {
+		s.ClearHealthCheckOverride(c.Param("name"))
+		return "", nil
+	}))
+	admin.Get("/actions", func(c *routing.Context) error {
+		return c.Write(s.AdminActions())
+	})
+	s.registerFaultEndpoints(admin)
+}
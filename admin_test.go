@@ -0,0 +1,168 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-ozzo/ozzo-routing"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAdminEndpoints(t *testing.T) {
+	r := routing.New()
+	se := NewStandardEndpoints()
+	se.SetLogger(nil)
+	se.RegisterDefaultEndpoints(r)
+	se.RegisterAdminEndpoints(CreateDefaultRouterGroup(r), BasicAuth(map[string]string{"alice": "secret"}))
+
+	serve := func(method, path string, authenticate bool) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		if authenticate {
+			req.SetBasicAuth("alice", "secret")
+		}
+		r.ServeHTTP(res, req)
+		return res
+	}
+
+	var dbRuns, cacheRuns int32
+	se.SetHealthCheckFuncs(time.Hour,
+		func() HealthCheckResult {
+			atomic.AddInt32(&dbRuns, 1)
+			return HealthCheckResult{Name: "db", Result: HealthResultPassed}
+		},
+		func() HealthCheckResult {
+			atomic.AddInt32(&cacheRuns, 1)
+			return HealthCheckResult{Name: "cache", Result: HealthResultPassed}
+		})
+	defer se.Close()
+	for atomic.LoadInt32(&dbRuns) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	Convey("Requests must be authenticated", t, func() {
+		res := serve("POST", "/service/admin/healthcheck/run", false)
+		So(res.Code, ShouldEqual, http.StatusUnauthorized)
+		So(res.Header().Get("WWW-Authenticate"), ShouldNotBeEmpty)
+
+		res = httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/service/admin/healthcheck/run", nil)
+		req.SetBasicAuth("alice", "wrong")
+		r.ServeHTTP(res, req)
+		So(res.Code, ShouldEqual, http.StatusUnauthorized)
+	})
+
+	Convey("Force a run", t, func() {
+		before := atomic.LoadInt32(&dbRuns)
+		res := serve("POST", "/service/admin/healthcheck/run", true)
+		So(res.Code, ShouldEqual, http.StatusOK)
+		So(contentType(res), ShouldStartWith, "application/json")
+		So(atomic.LoadInt32(&dbRuns), ShouldEqual, before+1)
+	})
+
+	Convey("Pause a single check", t, func() {
+		res := serve("POST", "/service/admin/healthcheck/checks/cache/pause", true)
+		So(res.Body.String(), ShouldContainSubstring, `"paused_checks":["cache"]`)
+
+		before := atomic.LoadInt32(&cacheRuns)
+		serve("POST", "/service/admin/healthcheck/run", true)
+		So(atomic.LoadInt32(&cacheRuns), ShouldEqual, before)
+		So(serve("GET", "/service/healthcheck", false).Body.String(), ShouldContainSubstring, `"test_name":"cache","test_result":"not_run"`)
+
+		serve("POST", "/service/admin/healthcheck/checks/cache/resume", true)
+		serve("POST", "/service/admin/healthcheck/run", true)
+		So(atomic.LoadInt32(&cacheRuns), ShouldEqual, before+1)
+	})
+
+	Convey("Override a check result", t, func() {
+		res := serve("POST", "/service/admin/healthcheck/checks/db/override?result=failed&duration=1h", true)
+		So(res.Code, ShouldEqual, http.StatusOK)
+		So(res.Body.String(), ShouldContainSubstring, `"set_by":"alice"`)
+		// no run needed, the override applies to the last report
+		So(serve("GET", "/service/healthcheck", false).Body.String(), ShouldContainSubstring, `"test_name":"db","test_result":"failed"`)
+
+		So(serve("POST", "/service/admin/healthcheck/checks/db/override?result=bogus&duration=1h", true).Code, ShouldEqual, http.StatusBadRequest)
+		So(serve("POST", "/service/admin/healthcheck/checks/db/override?result=failed", true).Code, ShouldEqual, http.StatusBadRequest)
+
+		serve("DELETE", "/service/admin/healthcheck/checks/db/override", true)
+		So(serve("GET", "/service/healthcheck", false).Body.String(), ShouldContainSubstring, `"test_name":"db","test_result":"passed"`)
+	})
+
+	Convey("Overrides expire", t, func() {
+		So(se.OverrideHealthCheck("db", HealthResultFailed, time.Millisecond, "bob"), ShouldBeNil)
+		time.Sleep(2 * time.Millisecond)
+		So(se.AdminState().Overrides, ShouldBeEmpty)
+		So(serve("GET", "/service/healthcheck", false).Body.String(), ShouldContainSubstring, `"test_name":"db","test_result":"passed"`)
+	})
+
+	Convey("Overrides apply and notify listeners while the scheduler is paused", t, func() {
+		se.PauseHealthChecks()
+		defer se.ResumeHealthChecks()
+		var transitions []HealthTransition
+		unsubscribe := se.SubscribeHealthTransitions(func(t HealthTransition) {
+			if t.Kind == HealthTransitionCheck {
+				transitions = append(transitions, t)
+			}
+		})
+		defer unsubscribe()
+
+		So(se.OverrideHealthCheck("db", HealthResultFailed, time.Hour, "bob"), ShouldBeNil)
+		So(serve("GET", "/service/healthcheck", false).Body.String(), ShouldContainSubstring, `"test_name":"db","test_result":"failed"`)
+		se.ClearHealthCheckOverride("db")
+		So(serve("GET", "/service/healthcheck", false).Body.String(), ShouldContainSubstring, `"test_name":"db","test_result":"passed"`)
+		So(len(transitions), ShouldEqual, 2)
+		So(transitions[0].Current, ShouldEqual, HealthResultFailed)
+		So(transitions[1].Current, ShouldEqual, HealthResultPassed)
+	})
+
+	Convey("Pause the scheduler", t, func() {
+		res := serve("POST", "/service/admin/healthcheck/pause", true)
+		So(res.Body.String(), ShouldContainSubstring, `"scheduler_paused":true`)
+		res = serve("POST", "/service/admin/healthcheck/resume", true)
+		So(res.Body.String(), ShouldContainSubstring, `"scheduler_paused":false`)
+	})
+
+	Convey("Actions are audited", t, func() {
+		res := serve("GET", "/service/admin/actions", true)
+		So(res.Code, ShouldEqual, http.StatusOK)
+		So(res.Body.String(), ShouldContainSubstring, `"user":"alice","action":"override","target":"db","detail":"failed for 1h0m0s"`)
+		So(res.Body.String(), ShouldContainSubstring, `"action":"pause","target":"cache"`)
+		So(res.Body.String(), ShouldNotContainSubstring, "bogus")
+	})
+}
+
+func TestBearerTokenAuth(t *testing.T) {
+	auth := BearerTokenAuth(map[string]string{"t0k3n": "deploy-bot"})
+	Convey("Bearer tokens map to users", t, func() {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer t0k3n")
+		user, ok := auth(req)
+		So(ok, ShouldBeTrue)
+		So(user, ShouldEqual, "deploy-bot")
+
+		req.Header.Set("Authorization", "Bearer nope")
+		_, ok = auth(req)
+		So(ok, ShouldBeFalse)
+	})
+}
@@ -0,0 +1,83 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"runtime/debug"
+)
+
+var (
+	// the build settings copied into BuildInfo.BuildSettings
+	DefaultBuildSettingKeys = []string{"CGO_ENABLED", "GOOS", "GOARCH", "GOAMD64", "GOARM", "GOARM64", "-race"}
+)
+
+// NewStandardEndpointsWithRuntimeBuildInfo fills in the build info from what the go tool embedded in the binary,
+// see RuntimeBuildInfo. Fields set in buildInfo, e.g. with -ldflags -X, are kept. buildInfo may be nil.
+func NewStandardEndpointsWithRuntimeBuildInfo(buildInfo *BuildInfo) *StandardEndpoints {
+	return NewStandardEndpointsWithBuildInfo(RuntimeBuildInfo(buildInfo))
+}
+
+// RuntimeBuildInfo returns a copy of buildInfo with the empty fields filled in from debug.ReadBuildInfo:
+// the git revision, commit time and dirty flag when built in a git checkout, the module path & version,
+// the go version and the build settings in DefaultBuildSettingKeys.
+func RuntimeBuildInfo(buildInfo *BuildInfo) *BuildInfo {
+	embedded, _ := debug.ReadBuildInfo()
+	return mergeBuildInfo(buildInfo, embedded)
+}
+
+func mergeBuildInfo(buildInfo *BuildInfo, embedded *debug.BuildInfo) *BuildInfo {
+	merged := BuildInfo{}
+	if buildInfo != nil {
+		merged = *buildInfo
+	}
+	if embedded == nil {
+		return &merged
+	}
+
+	setIfEmpty := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+	setIfEmpty(&merged.ArtifactID, embedded.Main.Path)
+	if embedded.Main.Version != "(devel)" {
+		setIfEmpty(&merged.Version, embedded.Main.Version)
+	}
+	setIfEmpty(&merged.GoVersion, embedded.GoVersion)
+
+	settings := map[string]string{}
+	for _, setting := range embedded.Settings {
+		settings[setting.Key] = setting.Value
+	}
+	setIfEmpty(&merged.GitSha1, settings["vcs.revision"])
+	setIfEmpty(&merged.BuiltWhen, settings["vcs.time"])
+	if settings["vcs.modified"] == "true" {
+		merged.GitDirty = true
+	}
+
+	if merged.BuildSettings == nil {
+		merged.BuildSettings = map[string]string{}
+		for _, key := range DefaultBuildSettingKeys {
+			if value, ok := settings[key]; ok {
+				merged.BuildSettings[key] = value
+			}
+		}
+	}
+	return &merged
+}
@@ -0,0 +1,82 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"runtime/debug"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRuntimeBuildInfo(t *testing.T) {
+	embedded := &debug.BuildInfo{
+		GoVersion: "go1.22.1",
+		Path:      "example.com/svc/cmd/svc",
+		Main:      debug.Module{Path: "example.com/svc", Version: "v1.4.0"},
+		Settings: []debug.BuildSetting{
+			{Key: "-race", Value: "true"},
+			{Key: "-ldflags", Value: "-X main.Version=2"},
+			{Key: "CGO_ENABLED", Value: "0"},
+			{Key: "GOARCH", Value: "amd64"},
+			{Key: "GOOS", Value: "linux"},
+			{Key: "GOAMD64", Value: "v3"},
+			{Key: "vcs", Value: "git"},
+			{Key: "vcs.revision", Value: "0123abcd"},
+			{Key: "vcs.time", Value: "2024-03-01T10:00:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+
+	Convey("Empty fields are filled from the embedded build info", t, func() {
+		bi := mergeBuildInfo(nil, embedded)
+		So(bi.ArtifactID, ShouldEqual, "example.com/svc")
+		So(bi.Version, ShouldEqual, "v1.4.0")
+		So(bi.GoVersion, ShouldEqual, "go1.22.1")
+		So(bi.GitSha1, ShouldEqual, "0123abcd")
+		So(bi.BuiltWhen, ShouldEqual, "2024-03-01T10:00:00Z")
+		So(bi.GitDirty, ShouldBeTrue)
+		So(bi.BuildSettings, ShouldResemble, map[string]string{
+			"CGO_ENABLED": "0", "GOOS": "linux", "GOARCH": "amd64", "GOAMD64": "v3", "-race": "true"})
+	})
+
+	Convey("Values that were provided win", t, func() {
+		bi := mergeBuildInfo(&BuildInfo{Version: "2", GitSha1: "feedbeef", BuiltBy: "ci"}, embedded)
+		So(bi.Version, ShouldEqual, "2")
+		So(bi.GitSha1, ShouldEqual, "feedbeef")
+		So(bi.BuiltBy, ShouldEqual, "ci")
+		So(bi.BuiltWhen, ShouldEqual, "2024-03-01T10:00:00Z")
+	})
+
+	Convey("A devel version is not used", t, func() {
+		devel := *embedded
+		devel.Main.Version = "(devel)"
+		So(mergeBuildInfo(nil, &devel).Version, ShouldEqual, "")
+	})
+
+	Convey("Without embedded build info the provided values are returned", t, func() {
+		bi := mergeBuildInfo(&BuildInfo{Version: "3"}, nil)
+		So(bi, ShouldResemble, &BuildInfo{Version: "3"})
+	})
+
+	Convey("The endpoints report the go version of the running binary", t, func() {
+		se := NewStandardEndpointsWithRuntimeBuildInfo(nil)
+		So(se.Status.GoVersion, ShouldStartWith, "go")
+	})
+}
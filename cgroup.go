@@ -0,0 +1,211 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"bufio"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+var (
+	DefaultCgroupRoot = "/sys/fs/cgroup"
+	DefaultProcSelf   = "/proc/self"
+
+	ErrNoCgroup = errors.New("se4: no cgroup found")
+
+	// the id where docker, containerd & cri-o put it, e.g. /docker/<id>, /containers/<id>/,
+	// docker-<id>.scope or cri-containerd-<id>.scope, but not other hashes such as the overlay upperdir
+	containerIDPattern = regexp.MustCompile(`(?:/docker/|/containers/|docker-|cri-containerd-|crio-)([0-9a-f]{64})(?:/|\.scope|\s|$)`)
+)
+
+// CgroupInfo is the CPU & memory the container is limited to, from cgroup v1 or v2.
+type CgroupInfo struct {
+	Version          int     `json:"version"`
+	ContainerID      string  `json:"container_id,omitempty"`
+	CPUQuota         float64 `json:"cpu_quota"`          // in CPUs, 0 when unlimited
+	MemoryLimitBytes uint64  `json:"memory_limit_bytes"` // 0 when unlimited
+	MemoryUsageBytes uint64  `json:"memory_usage_bytes"`
+	TotalPeriods     uint64  `json:"cpu_periods"`
+	ThrottledPeriods uint64  `json:"cpu_throttled_periods"`
+	ThrottledMillis  float64 `json:"cpu_throttled_millis"`
+}
+
+// ReadCgroupInfo reads the cgroup of this process from the cgroup filesystem mounted at cgroupRoot,
+// using the cgroup & mountinfo files in procSelf, normally DefaultCgroupRoot & DefaultProcSelf.
+func ReadCgroupInfo(cgroupRoot, procSelf string) (*CgroupInfo, error) {
+	paths := readProcCgroup(filepath.Join(procSelf, "cgroup"))
+	var info *CgroupInfo
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err == nil {
+		info = readCgroupV2(cgroupDir(cgroupRoot, paths[""]))
+	} else if _, err := os.Stat(filepath.Join(cgroupRoot, "memory")); err == nil {
+		info = readCgroupV1(cgroupRoot, paths)
+	} else {
+		return nil, ErrNoCgroup
+	}
+	info.ContainerID = containerID(procSelf)
+	return info, nil
+}
+
+func readCgroupV2(dir string) *CgroupInfo {
+	info := &CgroupInfo{Version: 2}
+	// "max 100000" or "<quota> <period>" in microseconds
+	if fields := strings.Fields(readString(filepath.Join(dir, "cpu.max"))); len(fields) == 2 && fields[0] != "max" {
+		info.CPUQuota = cpuQuota(fields[0], fields[1])
+	}
+	info.MemoryLimitBytes = readLimit(filepath.Join(dir, "memory.max"))
+	info.MemoryUsageBytes = readLimit(filepath.Join(dir, "memory.current"))
+	stat := readKeyValues(filepath.Join(dir, "cpu.stat"))
+	info.TotalPeriods, info.ThrottledPeriods = stat["nr_periods"], stat["nr_throttled"]
+	info.ThrottledMillis = float64(stat["throttled_usec"]) / 1000
+	return info
+}
+
+func readCgroupV1(root string, paths map[string]string) *CgroupInfo {
+	info := &CgroupInfo{Version: 1}
+	cpuDir := cgroupDir(filepath.Join(root, "cpu"), paths["cpu"])
+	if _, err := os.Stat(cpuDir); err != nil {
+		cpuDir = cgroupDir(filepath.Join(root, "cpu,cpuacct"), paths["cpu"])
+	}
+	// a quota of -1 is unlimited
+	quota := readString(filepath.Join(cpuDir, "cpu.cfs_quota_us"))
+	if !strings.HasPrefix(quota, "-") {
+		info.CPUQuota = cpuQuota(quota, readString(filepath.Join(cpuDir, "cpu.cfs_period_us")))
+	}
+	memoryDir := cgroupDir(filepath.Join(root, "memory"), paths["memory"])
+	info.MemoryLimitBytes = readLimit(filepath.Join(memoryDir, "memory.limit_in_bytes"))
+	info.MemoryUsageBytes = readLimit(filepath.Join(memoryDir, "memory.usage_in_bytes"))
+	stat := readKeyValues(filepath.Join(cpuDir, "cpu.stat"))
+	info.TotalPeriods, info.ThrottledPeriods = stat["nr_periods"], stat["nr_throttled"]
+	info.ThrottledMillis = float64(stat["throttled_time"]) / 1e6
+	return info
+}
+
+// the cgroup path of each v1 controller, keyed by "" for v2, from lines like "4:cpu,cpuacct:/docker/<id>"
+func readProcCgroup(path string) map[string]string {
+	paths := map[string]string{}
+	for _, line := range readLines(path) {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[1] == "" {
+			paths[""] = parts[2]
+			continue
+		}
+		for _, controller := range strings.Split(parts[1], ",") {
+			paths[controller] = parts[2]
+		}
+	}
+	return paths
+}
+
+// the directory of the cgroup, which is the mount itself when the container has its own cgroup namespace
+func cgroupDir(mount, path string) string {
+	if path != "" && path != "/" {
+		dir := filepath.Join(mount, path)
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+	}
+	return mount
+}
+
+// the docker / containerd id in the cgroup paths, or the mounts with a cgroup namespace
+func containerID(procSelf string) string {
+	for _, file := range []string{"cgroup", "mountinfo"} {
+		for _, line := range readLines(filepath.Join(procSelf, file)) {
+			if match := containerIDPattern.FindStringSubmatch(line); match != nil {
+				return match[1]
+			}
+		}
+	}
+	return ""
+}
+
+func cpuQuota(quota, period string) float64 {
+	q, err1 := strconv.ParseFloat(quota, 64)
+	p, err2 := strconv.ParseFloat(period, 64)
+	if err1 != nil || err2 != nil || p <= 0 || q <= 0 {
+		return 0
+	}
+	return q / p
+}
+
+// a number of bytes, with "max" and the v1 page aligned max int64 being unlimited, read as 0
+func readLimit(path string) uint64 {
+	value, err := strconv.ParseUint(readString(path), 10, 64)
+	if err != nil || value >= math.MaxInt64&^4095 {
+		return 0
+	}
+	return value
+}
+
+func readKeyValues(path string) map[string]uint64 {
+	values := map[string]uint64{}
+	for _, line := range readLines(path) {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			values[fields[0]], _ = strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	return values
+}
+
+func readString(path string) string {
+	data, _ := os.ReadFile(path)
+	return strings.TrimSpace(string(data))
+}
+
+func readLines(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+// Report os_numprocessors as the cgroup CPU quota, rounded up, rather than the CPUs of the host.
+func (s *StandardEndpoints) SetProcessorCountFromCPUQuota(enabled bool) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.quotaProcessors = enabled
+}
+
+// must be called with the lock held, after the cgroup has been read
+func (s *StandardEndpoints) numProcessors() int {
+	cpus := runtime.NumCPU()
+	if s.quotaProcessors && s.Status.Cgroup != nil && s.Status.Cgroup.CPUQuota > 0 {
+		if quota := int(math.Ceil(s.Status.Cgroup.CPUQuota)); quota < cpus {
+			return quota
+		}
+	}
+	return cpus
+}
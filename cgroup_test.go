@@ -0,0 +1,147 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	testContainerID = "3f4e2b0c9d1a8e7f6b5c4d3e2f1a0b9c8d7e6f5a4b3c2d1e0f9a8b7c6d5e4f3a"
+	testOverlayID   = "9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f1a0b9c8d7e6f5a4b3c2d1e0f9a8b"
+)
+
+// a fixture tree of the files, returning the cgroup root & proc self directories
+func cgroupFixture(t *testing.T, files map[string]string) (string, string) {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, "sys/fs/cgroup"), filepath.Join(dir, "proc/self")
+}
+
+func TestCgroupV2(t *testing.T) {
+	Convey("A cgroup v2 namespace is read from the root", t, func() {
+		root, proc := cgroupFixture(t, map[string]string{
+			"sys/fs/cgroup/cgroup.controllers": "cpu memory",
+			"sys/fs/cgroup/cpu.max":            "150000 100000\n",
+			"sys/fs/cgroup/memory.max":         "536870912\n",
+			"sys/fs/cgroup/memory.current":     "104857600\n",
+			"sys/fs/cgroup/cpu.stat":           "usage_usec 100\nnr_periods 50\nnr_throttled 5\nthrottled_usec 2500\n",
+			"proc/self/cgroup":                 "0::/\n",
+			"proc/self/mountinfo": "1 0 0:1 / / rw - overlay overlay rw,lowerdir=/var/lib/docker/overlay2/l/ABC,upperdir=/var/lib/docker/overlay2/" + testOverlayID + "/diff\n" +
+				"2 1 0:2 /var/lib/docker/containers/" + testContainerID + "/hostname /etc/hostname rw\n",
+		})
+		info, err := ReadCgroupInfo(root, proc)
+		So(err, ShouldBeNil)
+		So(info, ShouldResemble, &CgroupInfo{Version: 2, ContainerID: testContainerID, CPUQuota: 1.5,
+			MemoryLimitBytes: 536870912, MemoryUsageBytes: 104857600,
+			TotalPeriods: 50, ThrottledPeriods: 5, ThrottledMillis: 2.5})
+	})
+
+	Convey("Unlimited cgroup v2 limits read as 0, from the process's own cgroup", t, func() {
+		root, proc := cgroupFixture(t, map[string]string{
+			"sys/fs/cgroup/cgroup.controllers":           "cpu memory",
+			"sys/fs/cgroup/kubepods/pod1/cpu.max":        "max 100000\n",
+			"sys/fs/cgroup/kubepods/pod1/memory.max":     "max\n",
+			"sys/fs/cgroup/kubepods/pod1/memory.current": "2048\n",
+			"proc/self/cgroup":                           "0::/kubepods/pod1\n",
+		})
+		info, err := ReadCgroupInfo(root, proc)
+		So(err, ShouldBeNil)
+		So(info.CPUQuota, ShouldEqual, 0)
+		So(info.MemoryLimitBytes, ShouldEqual, 0)
+		So(info.MemoryUsageBytes, ShouldEqual, 2048)
+		So(info.ContainerID, ShouldEqual, "")
+	})
+
+	Convey("The container id is taken from the systemd scope, not other hashes", t, func() {
+		root, proc := cgroupFixture(t, map[string]string{
+			"sys/fs/cgroup/cgroup.controllers": "cpu memory",
+			"proc/self/cgroup":                 "0::/system.slice/cri-containerd-" + testContainerID + ".scope\n",
+			"proc/self/mountinfo":              "1 0 0:1 / / rw - overlay overlay rw,upperdir=/var/lib/docker/overlay2/" + testOverlayID + "/diff\n",
+		})
+		info, err := ReadCgroupInfo(root, proc)
+		So(err, ShouldBeNil)
+		So(info.ContainerID, ShouldEqual, testContainerID)
+
+		os.WriteFile(filepath.Join(proc, "cgroup"), []byte("0::/\n"), 0644)
+		info, err = ReadCgroupInfo(root, proc)
+		So(err, ShouldBeNil)
+		So(info.ContainerID, ShouldEqual, "")
+	})
+}
+
+func TestCgroupV1(t *testing.T) {
+	Convey("cgroup v1 controllers are read", t, func() {
+		root, proc := cgroupFixture(t, map[string]string{
+			"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_quota_us":  "50000\n",
+			"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_period_us": "100000\n",
+			"sys/fs/cgroup/cpu,cpuacct/cpu.stat":          "nr_periods 10\nnr_throttled 2\nthrottled_time 3000000\n",
+			"sys/fs/cgroup/memory/memory.limit_in_bytes":  "268435456\n",
+			"sys/fs/cgroup/memory/memory.usage_in_bytes":  "1048576\n",
+			"proc/self/cgroup":                            "4:memory:/docker/" + testContainerID + "\n3:cpu,cpuacct:/docker/" + testContainerID + "\n",
+		})
+		info, err := ReadCgroupInfo(root, proc)
+		So(err, ShouldBeNil)
+		So(info, ShouldResemble, &CgroupInfo{Version: 1, ContainerID: testContainerID, CPUQuota: 0.5,
+			MemoryLimitBytes: 268435456, MemoryUsageBytes: 1048576,
+			TotalPeriods: 10, ThrottledPeriods: 2, ThrottledMillis: 3})
+	})
+
+	Convey("Unlimited cgroup v1 limits read as 0", t, func() {
+		root, proc := cgroupFixture(t, map[string]string{
+			"sys/fs/cgroup/cpu/cpu.cfs_quota_us":         "-1\n",
+			"sys/fs/cgroup/cpu/cpu.cfs_period_us":        "100000\n",
+			"sys/fs/cgroup/memory/memory.limit_in_bytes": "9223372036854771712\n",
+		})
+		info, err := ReadCgroupInfo(root, proc)
+		So(err, ShouldBeNil)
+		So(info.CPUQuota, ShouldEqual, 0)
+		So(info.MemoryLimitBytes, ShouldEqual, 0)
+	})
+
+	Convey("Without a cgroup filesystem there is no info", t, func() {
+		root, proc := cgroupFixture(t, map[string]string{"proc/self/cgroup": ""})
+		_, err := ReadCgroupInfo(root, proc)
+		So(err, ShouldEqual, ErrNoCgroup)
+	})
+}
+
+func TestProcessorCountFromCPUQuota(t *testing.T) {
+	se := NewStandardEndpoints()
+
+	Convey("The processor count is rounded up from the quota when enabled", t, func() {
+		se.Status.Cgroup = &CgroupInfo{CPUQuota: 0.5}
+		So(se.numProcessors(), ShouldEqual, runtime.NumCPU())
+		se.SetProcessorCountFromCPUQuota(true)
+		So(se.numProcessors(), ShouldEqual, 1)
+		se.Status.Cgroup = &CgroupInfo{CPUQuota: float64(runtime.NumCPU() + 4)}
+		So(se.numProcessors(), ShouldEqual, runtime.NumCPU())
+	})
+}
@@ -0,0 +1,183 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	DefaultMetadataTimeout = time.Second // for each request to the metadata server
+
+	DefaultEC2MetadataURL = "http://169.254.169.254"
+	DefaultGCEMetadataURL = "http://metadata.google.internal"
+)
+
+// the metadata servers are link-local, so never go through a proxy from HTTP_PROXY and the like
+var metadataTransport = newMetadataTransport()
+
+func newMetadataTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	return transport
+}
+
+// InstanceMetadata identifies the cloud VM the service runs on.
+type InstanceMetadata struct {
+	Provider         string `json:"provider"`
+	InstanceID       string `json:"instance_id"`
+	InstanceType     string `json:"instance_type"`
+	AvailabilityZone string `json:"availability_zone"`
+	ImageID          string `json:"image_id"`
+}
+
+// MetadataProvider reads the instance metadata from a cloud's metadata server.
+type MetadataProvider interface {
+	Name() string
+	Fetch(ctx context.Context) (*InstanceMetadata, error)
+}
+
+// Fetch the instance metadata once and show it in the status as "instance".
+// Nothing is shown if it fails, e.g. when not running on that cloud.
+func (s *StandardEndpoints) SetMetadataProvider(ctx context.Context, provider MetadataProvider) error {
+	metadata, err := provider.Fetch(ctx)
+	if err != nil {
+		s.log("fetching instance metadata failed", "provider", provider.Name(), "error", err.Error())
+		return err
+	}
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.Status.Instance = metadata
+	return nil
+}
+
+// EC2Metadata reads the instance metadata with IMDSv2.
+type EC2Metadata struct {
+	BaseURL string // DefaultEC2MetadataURL if empty
+	Client  *http.Client
+}
+
+func (m *EC2Metadata) Name() string {
+	return "ec2"
+}
+
+func (m *EC2Metadata) Fetch(ctx context.Context) (*InstanceMetadata, error) {
+	base := strings.TrimSuffix(orDefault(m.BaseURL, DefaultEC2MetadataURL), "/")
+	client := metadataClient(m.Client)
+
+	// IMDSv2 needs a session token
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, base+"/latest/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	token, err := fetchMetadata(client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &InstanceMetadata{Provider: m.Name()}
+	for path, field := range map[string]*string{
+		"instance-id":                 &metadata.InstanceID,
+		"instance-type":               &metadata.InstanceType,
+		"placement/availability-zone": &metadata.AvailabilityZone,
+		"ami-id":                      &metadata.ImageID,
+	} {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/latest/meta-data/"+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-aws-ec2-metadata-token", token)
+		if *field, err = fetchMetadata(client, req); err != nil {
+			return nil, err
+		}
+	}
+	return metadata, nil
+}
+
+// GCEMetadata reads the instance metadata from the GCE metadata server.
+type GCEMetadata struct {
+	BaseURL string // DefaultGCEMetadataURL if empty
+	Client  *http.Client
+}
+
+func (m *GCEMetadata) Name() string {
+	return "gce"
+}
+
+func (m *GCEMetadata) Fetch(ctx context.Context) (*InstanceMetadata, error) {
+	base := strings.TrimSuffix(orDefault(m.BaseURL, DefaultGCEMetadataURL), "/")
+	client := metadataClient(m.Client)
+
+	metadata := &InstanceMetadata{Provider: m.Name()}
+	for path, field := range map[string]*string{
+		"id":           &metadata.InstanceID,
+		"machine-type": &metadata.InstanceType,
+		"zone":         &metadata.AvailabilityZone,
+		"image":        &metadata.ImageID,
+	} {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/computeMetadata/v1/instance/"+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+		if *field, err = fetchMetadata(client, req); err != nil {
+			return nil, err
+		}
+	}
+	// the machine type & zone are "projects/<number>/zones/<zone>", only the last part is interesting
+	metadata.InstanceType = metadata.InstanceType[strings.LastIndex(metadata.InstanceType, "/")+1:]
+	metadata.AvailabilityZone = metadata.AvailabilityZone[strings.LastIndex(metadata.AvailabilityZone, "/")+1:]
+	return metadata, nil
+}
+
+func fetchMetadata(client *http.Client, req *http.Request) (string, error) {
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, 64*1024))
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s %s: %s", req.Method, req.URL.Path, res.Status)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+func metadataClient(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+	return &http.Client{Timeout: DefaultMetadataTimeout, Transport: metadataTransport}
+}
+
+func orDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
@@ -0,0 +1,142 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func fakeEC2MetadataServer() *httptest.Server {
+	values := map[string]string{
+		"/latest/meta-data/instance-id":                 "i-0123456789abcdef0",
+		"/latest/meta-data/instance-type":               "m5.large",
+		"/latest/meta-data/placement/availability-zone": "eu-west-1b",
+		"/latest/meta-data/ami-id":                      "ami-0abcdef",
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest/api/token" {
+			if r.Method != http.MethodPut || r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte("session-token"))
+			return
+		}
+		if r.Header.Get("X-aws-ec2-metadata-token") != "session-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		value, ok := values[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(value))
+	}))
+}
+
+func fakeGCEMetadataServer() *httptest.Server {
+	values := map[string]string{
+		"/computeMetadata/v1/instance/id":           "4567890123",
+		"/computeMetadata/v1/instance/machine-type": "projects/123/machineTypes/n2-standard-4",
+		"/computeMetadata/v1/instance/zone":         "projects/123/zones/us-central1-a",
+		"/computeMetadata/v1/instance/image":        "projects/debian-cloud/global/images/debian-12",
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value, ok := values[r.URL.Path]
+		if r.Header.Get("Metadata-Flavor") != "Google" || !ok {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Metadata-Flavor", "Google")
+		w.Write([]byte(value))
+	}))
+}
+
+func TestEC2Metadata(t *testing.T) {
+	server := fakeEC2MetadataServer()
+	defer server.Close()
+
+	Convey("EC2 metadata is read with an IMDSv2 token", t, func() {
+		metadata, err := (&EC2Metadata{BaseURL: server.URL}).Fetch(context.Background())
+		So(err, ShouldBeNil)
+		So(metadata, ShouldResemble, &InstanceMetadata{Provider: "ec2", InstanceID: "i-0123456789abcdef0",
+			InstanceType: "m5.large", AvailabilityZone: "eu-west-1b", ImageID: "ami-0abcdef"})
+	})
+
+	Convey("The metadata is shown in the status once fetched", t, func() {
+		se := NewStandardEndpoints()
+		se.SetLogger(nil)
+		So(se.SetMetadataProvider(context.Background(), &EC2Metadata{BaseURL: server.URL}), ShouldBeNil)
+		server.Close()
+		So(se.generateStatus().Instance.InstanceID, ShouldEqual, "i-0123456789abcdef0")
+	})
+}
+
+func TestGCEMetadata(t *testing.T) {
+	server := fakeGCEMetadataServer()
+	defer server.Close()
+
+	Convey("GCE metadata is read with the metadata flavor header", t, func() {
+		metadata, err := (&GCEMetadata{BaseURL: server.URL}).Fetch(context.Background())
+		So(err, ShouldBeNil)
+		So(metadata, ShouldResemble, &InstanceMetadata{Provider: "gce", InstanceID: "4567890123",
+			InstanceType: "n2-standard-4", AvailabilityZone: "us-central1-a",
+			ImageID: "projects/debian-cloud/global/images/debian-12"})
+	})
+
+	Convey("Another cloud's metadata server is an error", t, func() {
+		_, err := (&EC2Metadata{BaseURL: server.URL}).Fetch(context.Background())
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestMetadataTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	Convey("A slow metadata server times out and nothing is shown", t, func() {
+		se := NewStandardEndpoints()
+		se.SetLogger(nil)
+		start := time.Now()
+		provider := &GCEMetadata{BaseURL: server.URL, Client: &http.Client{Timeout: 20 * time.Millisecond}}
+		So(se.SetMetadataProvider(context.Background(), provider), ShouldNotBeNil)
+		So(time.Since(start), ShouldBeLessThan, 500*time.Millisecond)
+		So(se.generateStatus().Instance, ShouldBeNil)
+	})
+}
+
+func TestMetadataClientSkipsProxy(t *testing.T) {
+	Convey("The default metadata client doesn't use a proxy", t, func() {
+		client := metadataClient(nil)
+		So(client.Timeout, ShouldEqual, DefaultMetadataTimeout)
+		So(client.Transport.(*http.Transport).Proxy, ShouldBeNil)
+	})
+}
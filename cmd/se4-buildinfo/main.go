@@ -0,0 +1,295 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+// se4-buildinfo generates a Go source file, or JSON to embed, that fills se4.BuildInfo for builds
+// where the VCS stamp read by se4.RuntimeBuildInfo is missing, e.g. from a tarball.
+//
+// Values are taken from a JSON file given with -from, then CI environment variables, then git:
+//
+//	//go:generate go run github.com/jdamick/ozzo-se4/cmd/se4-buildinfo -o buildinfo_gen.go
+//
+// -check exits with an error instead of writing when the file is out of date. It compares what is built,
+// the artifact id, version, repo & runbook, along with the package, variable and formatting, but not the
+// values describing a particular build or commit, which change with every build and when the generated
+// file is committed. The generated file itself doesn't make the working tree dirty.
+//
+// Pass the generated BuildInfo to se4.NewStandardEndpointsWithRuntimeBuildInfo to add the go version
+// and build settings.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	se4 "github.com/jdamick/ozzo-se4"
+)
+
+// the first environment variable that is set wins, covering jenkins, github actions & gitlab
+var envVars = map[string][]string{
+	"artifact_id":   {"SE4_ARTIFACT_ID"},
+	"version":       {"SE4_VERSION", "VERSION"},
+	"build_number":  {"SE4_BUILD_NUMBER", "BUILD_NUMBER", "GITHUB_RUN_NUMBER", "CI_PIPELINE_IID"},
+	"build_machine": {"SE4_BUILD_MACHINE", "NODE_NAME", "RUNNER_NAME", "CI_RUNNER_DESCRIPTION"},
+	"built_by":      {"SE4_BUILT_BY", "BUILD_USER", "GITHUB_ACTOR", "GITLAB_USER_LOGIN"},
+	"git_sha1":      {"SE4_GIT_SHA1", "GIT_COMMIT", "GITHUB_SHA", "CI_COMMIT_SHA"},
+	"git_branch":    {"SE4_GIT_BRANCH", "GIT_BRANCH", "GITHUB_REF_NAME", "CI_COMMIT_REF_NAME"},
+	"git_repo":      {"SE4_GIT_REPO", "GIT_URL", "CI_PROJECT_URL"},
+	"runbook_uri":   {"SE4_RUNBOOK_URI"},
+}
+
+// the git command for a field, used when neither the file nor the environment has it
+var gitCommands = map[string][]string{
+	"git_sha1":   {"rev-parse", "HEAD"},
+	"git_branch": {"rev-parse", "--abbrev-ref", "HEAD"},
+	"git_repo":   {"config", "--get", "remote.origin.url"},
+	"built_when": {"log", "-1", "--format=%cI"},
+}
+
+// the fields describing a particular build or commit rather than what is built, -check ignores them
+var volatileFields = []string{"BuildNumber", "BuildMachine", "BuiltBy", "BuiltWhen", "GitSha1", "GitBranch", "GitDirty"}
+
+type options struct {
+	output  string
+	format  string // "go" or "json"
+	pkg     string
+	varName string
+	from    string
+	check   bool
+}
+
+// the sources of the values, replaced in tests
+type sources struct {
+	getenv func(key string) string
+	git    func(args ...string) (string, error)
+}
+
+func main() {
+	opts := options{}
+	flag.StringVar(&opts.output, "o", "buildinfo_gen.go", "the file to write")
+	flag.StringVar(&opts.format, "format", "go", `"go" for a Go source file or "json" to embed`)
+	flag.StringVar(&opts.pkg, "package", os.Getenv("GOPACKAGE"), "the package of the Go source file, defaults to the one running go generate")
+	flag.StringVar(&opts.varName, "var", "BuildInfo", "the name of the *se4.BuildInfo variable in the Go source file")
+	flag.StringVar(&opts.from, "from", "", "a JSON file of se4.BuildInfo values, which take precedence")
+	flag.BoolVar(&opts.check, "check", false, "exit with an error if the file is not up to date instead of writing it, ignoring the build & commit specific values")
+	flag.Parse()
+
+	if err := run(opts, sources{getenv: os.Getenv, git: git}); err != nil {
+		fmt.Fprintln(os.Stderr, "se4-buildinfo:", err)
+		os.Exit(1)
+	}
+}
+
+func run(opts options, src sources) error {
+	buildInfo, err := collect(opts, src)
+	if err != nil {
+		return err
+	}
+	if !opts.check {
+		generated, err := render(opts, buildInfo)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(opts.output, generated, 0644)
+	}
+
+	existing, err := os.ReadFile(opts.output)
+	if err != nil {
+		return err
+	}
+	previous, err := parseGenerated(opts.format, existing)
+	if err != nil {
+		return fmt.Errorf("%s: %w", opts.output, err)
+	}
+	// keep the build & commit specific values of the existing file, so only the rest is compared
+	expected := reflect.ValueOf(buildInfo).Elem()
+	for _, name := range volatileFields {
+		expected.FieldByName(name).Set(reflect.ValueOf(previous).Elem().FieldByName(name))
+	}
+	generated, err := render(opts, buildInfo)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(existing, generated) {
+		return fmt.Errorf("%s is out of date, run go generate", opts.output)
+	}
+	return nil
+}
+
+func collect(opts options, src sources) (*se4.BuildInfo, error) {
+	values := map[string]string{}
+	dirty := false
+	if from := opts.from; from != "" {
+		data, err := os.ReadFile(from)
+		if err != nil {
+			return nil, err
+		}
+		fromFile := se4.BuildInfo{}
+		if err := json.Unmarshal(data, &fromFile); err != nil {
+			return nil, fmt.Errorf("%s: %w", from, err)
+		}
+		dirty = fromFile.GitDirty
+		// round trip through json to key the values the same way as the environment
+		data, _ = json.Marshal(fromFile)
+		fields := map[string]interface{}{}
+		json.Unmarshal(data, &fields)
+		for key, value := range fields {
+			if str, ok := value.(string); ok && str != "" {
+				values[key] = str
+			}
+		}
+	}
+
+	for key, names := range envVars {
+		for _, name := range names {
+			if _, found := values[key]; !found && src.getenv(name) != "" {
+				values[key] = src.getenv(name)
+			}
+		}
+	}
+	if _, found := values["git_repo"]; !found && src.getenv("GITHUB_REPOSITORY") != "" {
+		values["git_repo"] = strings.TrimSuffix(src.getenv("GITHUB_SERVER_URL"), "/") + "/" + src.getenv("GITHUB_REPOSITORY")
+	}
+	if _, found := values["built_when"]; !found && src.getenv("SOURCE_DATE_EPOCH") != "" {
+		epoch, err := strconv.ParseInt(src.getenv("SOURCE_DATE_EPOCH"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("SOURCE_DATE_EPOCH: %w", err)
+		}
+		values["built_when"] = time.Unix(epoch, 0).UTC().Format(time.RFC3339)
+	}
+
+	// git is only needed for what's still missing, it isn't there for a tarball
+	for key, args := range gitCommands {
+		if _, found := values[key]; !found {
+			if out, err := src.git(args...); err == nil && out != "" {
+				values[key] = out
+			}
+		}
+	}
+
+	buildInfo := &se4.BuildInfo{}
+	data, _ := json.Marshal(values)
+	if err := json.Unmarshal(data, buildInfo); err != nil {
+		return nil, err
+	}
+	// changes anywhere in the repo, except to the file being generated
+	if out, err := src.git("status", "--porcelain", "--", ":/", ":(exclude)"+opts.output); err == nil && out != "" {
+		dirty = true
+	}
+	buildInfo.GitDirty = dirty
+	return buildInfo, nil
+}
+
+func git(args ...string) (string, error) {
+	out, err := exec.Command("git", args...).Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+var goTemplate = template.Must(template.New("go").Parse(`// Code generated by se4-buildinfo; DO NOT EDIT.
+
+package {{.Package}}
+
+import se4 "github.com/jdamick/ozzo-se4"
+
+// {{.Var}} was generated by se4-buildinfo.
+var {{.Var}} = &se4.BuildInfo{
+	ArtifactID: {{printf "%q" .Info.ArtifactID}},
+	BuildNumber: {{printf "%q" .Info.BuildNumber}},
+	BuildMachine: {{printf "%q" .Info.BuildMachine}},
+	BuiltBy: {{printf "%q" .Info.BuiltBy}},
+	BuiltWhen: {{printf "%q" .Info.BuiltWhen}},
+	GitSha1: {{printf "%q" .Info.GitSha1}},
+	GitBranch: {{printf "%q" .Info.GitBranch}},
+	GitRepo: {{printf "%q" .Info.GitRepo}},
+	RunbookURI: {{printf "%q" .Info.RunbookURI}},
+	Version: {{printf "%q" .Info.Version}},
+	GitDirty: {{.Info.GitDirty}},
+}
+`))
+
+// read the values back from a generated file
+func parseGenerated(format string, data []byte) (*se4.BuildInfo, error) {
+	buildInfo := &se4.BuildInfo{}
+	if format == "json" {
+		return buildInfo, json.Unmarshal(data, buildInfo)
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), "", data, 0)
+	if err != nil {
+		return nil, err
+	}
+	fields := reflect.ValueOf(buildInfo).Elem()
+	ast.Inspect(file, func(n ast.Node) bool {
+		kv, ok := n.(*ast.KeyValueExpr)
+		if !ok {
+			return true
+		}
+		key, ok := kv.Key.(*ast.Ident)
+		if !ok {
+			return false
+		}
+		field := fields.FieldByName(key.Name)
+		switch value := kv.Value.(type) {
+		case *ast.BasicLit:
+			if str, err := strconv.Unquote(value.Value); err == nil && field.Kind() == reflect.String {
+				field.SetString(str)
+			}
+		case *ast.Ident:
+			if field.Kind() == reflect.Bool {
+				field.SetBool(value.Name == "true")
+			}
+		}
+		return false
+	})
+	return buildInfo, nil
+}
+
+func render(opts options, buildInfo *se4.BuildInfo) ([]byte, error) {
+	switch opts.format {
+	case "json":
+		data, err := json.MarshalIndent(buildInfo, "", "  ")
+		return append(data, '\n'), err
+	case "go":
+		pkg := opts.pkg
+		if pkg == "" {
+			pkg = "main"
+		}
+		var buf bytes.Buffer
+		err := goTemplate.Execute(&buf, struct {
+			Package, Var string
+			Info         *se4.BuildInfo
+		}{pkg, opts.varName, buildInfo})
+		if err != nil {
+			return nil, err
+		}
+		return format.Source(buf.Bytes())
+	default:
+		return nil, fmt.Errorf("unknown format %q", opts.format)
+	}
+}
@@ -0,0 +1,150 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	se4 "github.com/jdamick/ozzo-se4"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func fakeSources(env map[string]string, gitOutput map[string]string) sources {
+	return sources{
+		getenv: func(key string) string { return env[key] },
+		git: func(args ...string) (string, error) {
+			out, ok := gitOutput[strings.Join(args, " ")]
+			if !ok {
+				return "", errors.New("not a git repository")
+			}
+			return out, nil
+		},
+	}
+}
+
+func TestCollect(t *testing.T) {
+	gitOutput := map[string]string{
+		"rev-parse HEAD":                                      "0123abcd",
+		"rev-parse --abbrev-ref HEAD":                         "main",
+		"config --get remote.origin.url":                      "git@example.com:svc.git",
+		"log -1 --format=%cI":                                 "2024-03-01T10:00:00Z",
+		"status --porcelain -- :/ :(exclude)":                 " M main.go",
+		"status --porcelain -- :/ :(exclude)buildinfo_gen.go": "",
+	}
+
+	Convey("Values come from git when nothing else has them", t, func() {
+		bi, err := collect(options{}, fakeSources(nil, gitOutput))
+		So(err, ShouldBeNil)
+		So(bi.GitSha1, ShouldEqual, "0123abcd")
+		So(bi.GitBranch, ShouldEqual, "main")
+		So(bi.GitRepo, ShouldEqual, "git@example.com:svc.git")
+		So(bi.BuiltWhen, ShouldEqual, "2024-03-01T10:00:00Z")
+		So(bi.GitDirty, ShouldBeTrue)
+	})
+
+	Convey("The generated file doesn't make the tree dirty", t, func() {
+		bi, err := collect(options{output: "buildinfo_gen.go"}, fakeSources(nil, gitOutput))
+		So(err, ShouldBeNil)
+		So(bi.GitDirty, ShouldBeFalse)
+	})
+
+	Convey("CI environment variables take precedence over git", t, func() {
+		env := map[string]string{"GITHUB_SHA": "feedbeef", "GITHUB_RUN_NUMBER": "42", "RUNNER_NAME": "runner-1",
+			"GITHUB_SERVER_URL": "https://github.com", "GITHUB_REPOSITORY": "acme/svc", "SOURCE_DATE_EPOCH": "0"}
+		bi, err := collect(options{}, fakeSources(env, gitOutput))
+		So(err, ShouldBeNil)
+		So(bi.GitSha1, ShouldEqual, "feedbeef")
+		So(bi.BuildNumber, ShouldEqual, "42")
+		So(bi.BuildMachine, ShouldEqual, "runner-1")
+		So(bi.GitRepo, ShouldEqual, "https://github.com/acme/svc")
+		So(bi.BuiltWhen, ShouldEqual, "1970-01-01T00:00:00Z")
+		So(bi.GitBranch, ShouldEqual, "main")
+	})
+
+	Convey("A JSON file takes precedence over everything, and git isn't needed", t, func() {
+		from := filepath.Join(t.TempDir(), "buildinfo.json")
+		data, _ := json.Marshal(se4.BuildInfo{GitSha1: "cafe", Version: "1.2.3", ArtifactID: "svc"})
+		os.WriteFile(from, data, 0644)
+		bi, err := collect(options{from: from}, fakeSources(map[string]string{"GIT_COMMIT": "beef", "BUILD_NUMBER": "7"}, nil))
+		So(err, ShouldBeNil)
+		So(bi.GitSha1, ShouldEqual, "cafe")
+		So(bi.Version, ShouldEqual, "1.2.3")
+		So(bi.ArtifactID, ShouldEqual, "svc")
+		So(bi.BuildNumber, ShouldEqual, "7")
+		So(bi.GitDirty, ShouldBeFalse)
+	})
+
+	Convey("A bad SOURCE_DATE_EPOCH is an error", t, func() {
+		_, err := collect(options{}, fakeSources(map[string]string{"SOURCE_DATE_EPOCH": "yesterday"}, nil))
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestRun(t *testing.T) {
+	src := fakeSources(map[string]string{"SE4_VERSION": "1.0", "GIT_COMMIT": "0123abcd"}, nil)
+	dir := t.TempDir()
+
+	Convey("A Go source file is generated", t, func() {
+		opts := options{output: filepath.Join(dir, "buildinfo_gen.go"), format: "go", pkg: "svc", varName: "BuildInfo"}
+		So(run(opts, src), ShouldBeNil)
+		data, _ := os.ReadFile(opts.output)
+		So(string(data), ShouldStartWith, "// Code generated by se4-buildinfo; DO NOT EDIT.\n\npackage svc\n")
+		So(string(data), ShouldContainSubstring, `GitSha1:      "0123abcd",`)
+		So(string(data), ShouldContainSubstring, `Version:      "1.0",`)
+
+		Convey("Check passes while it is up to date", func() {
+			opts.check = true
+			So(run(opts, src), ShouldBeNil)
+			changed := fakeSources(map[string]string{"SE4_VERSION": "1.1", "GIT_COMMIT": "0123abcd"}, nil)
+			So(run(opts, changed), ShouldNotBeNil)
+		})
+
+		Convey("Check ignores the build & commit, which change once the file is committed", func() {
+			opts.check = true
+			committed := fakeSources(map[string]string{"SE4_VERSION": "1.0", "GIT_COMMIT": "4567ef01", "BUILD_NUMBER": "8"},
+				map[string]string{"status --porcelain -- :/ :(exclude)" + opts.output: " M main.go"})
+			So(run(opts, committed), ShouldBeNil)
+			opts.pkg = "other"
+			So(run(opts, committed), ShouldNotBeNil)
+		})
+	})
+
+	Convey("JSON is generated to embed", t, func() {
+		opts := options{output: filepath.Join(dir, "buildinfo.json"), format: "json"}
+		So(run(opts, src), ShouldBeNil)
+		data, _ := os.ReadFile(opts.output)
+		bi := se4.BuildInfo{}
+		So(json.Unmarshal(data, &bi), ShouldBeNil)
+		So(bi.Version, ShouldEqual, "1.0")
+
+		opts.check = true
+		So(run(opts, fakeSources(map[string]string{"SE4_VERSION": "1.0", "GIT_COMMIT": "4567ef01"}, nil)), ShouldBeNil)
+		So(run(opts, fakeSources(map[string]string{"SE4_VERSION": "2.0"}, nil)), ShouldNotBeNil)
+	})
+
+	Convey("Check fails when the file is missing", t, func() {
+		opts := options{output: filepath.Join(dir, "missing.go"), format: "go", check: true}
+		So(run(opts, src), ShouldNotBeNil)
+	})
+}
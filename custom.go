@@ -0,0 +1,125 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// StatusProviderFunc returns a field to add to /service/status, it is called for each request.
+type StatusProviderFunc func() (key string, value interface{})
+
+// the json keys of Status, which custom fields can't use
+var reservedStatusKeys = jsonKeys(reflect.TypeOf(Status{}))
+
+func jsonKeys(t reflect.Type) map[string]bool {
+	keys := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch {
+		case name == "-" || !field.IsExported():
+		case field.Anonymous && name == "":
+			for key := range jsonKeys(field.Type) {
+				keys[key] = true
+			}
+		case name == "":
+			keys[field.Name] = true
+		default:
+			keys[name] = true
+		}
+	}
+	return keys
+}
+
+// Add a field to /service/status, e.g. the shard or feature set. Setting it again replaces the value.
+// The key can't be one of the SE4 keys of Status.
+func (s *StandardEndpoints) SetStatusField(key string, value interface{}) error {
+	if reservedStatusKeys[key] {
+		return fmt.Errorf("status field %q is reserved by se4", key)
+	}
+	if _, err := json.Marshal(value); err != nil {
+		return fmt.Errorf("status field %q: %w", key, err)
+	}
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.statusFields[key] = value
+	return nil
+}
+
+// Add a provider of a dynamic field to /service/status, e.g. the leader/follower role.
+// A field from a provider with the key of an SE4 or static field is left out.
+func (s *StandardEndpoints) AddStatusProvider(provider StatusProviderFunc) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.statusProviders = append(s.statusProviders, provider)
+}
+
+// the status json with the custom fields appended in order of their keys
+func (s *StandardEndpoints) statusJSON(status *Status) (json.RawMessage, error) {
+	s.locker.Lock()
+	fields := make(map[string]interface{}, len(s.statusFields))
+	for key, value := range s.statusFields {
+		fields[key] = value
+	}
+	providers := append([]StatusProviderFunc(nil), s.statusProviders...)
+	s.locker.Unlock()
+
+	// providers may be slow, so are called without the lock
+	for _, provider := range providers {
+		key, value := provider()
+		if _, found := fields[key]; found || reservedStatusKeys[key] {
+			s.log("status provider field left out, the key is already used", "key", key)
+			continue
+		}
+		fields[key] = value
+	}
+
+	data, err := json.Marshal(status)
+	if err != nil || len(fields) == 0 {
+		return data, err
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.Write(data[:len(data)-1]) // without the closing brace
+	for _, key := range keys {
+		value, err := json.Marshal(fields[key])
+		if err != nil {
+			s.log("status field left out, it can't be marshalled", "key", key, "error", err.Error())
+			continue
+		}
+		name, _ := json.Marshal(key)
+		buf.WriteByte(',')
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,78 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-ozzo/ozzo-routing"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCustomStatusFields(t *testing.T) {
+	r := routing.New()
+	se := NewStandardEndpoints()
+	se.SetLogger(nil)
+	se.RegisterDefaultEndpoints(r)
+
+	status := func() map[string]interface{} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/service/status", nil)
+		r.ServeHTTP(res, req)
+		fields := map[string]interface{}{}
+		So(json.Unmarshal(res.Body.Bytes(), &fields), ShouldBeNil)
+		return fields
+	}
+
+	Convey("SE4 keys are reserved", t, func() {
+		So(se.SetStatusField("version", "x"), ShouldNotBeNil)
+		So(se.SetStatusField("machine_name", "x"), ShouldNotBeNil)
+		So(se.SetStatusField("artifact_id", "x"), ShouldNotBeNil) // from the embedded BuildInfo
+		So(se.SetStatusField("UpSinceTime", "x"), ShouldBeNil)    // not in the json
+		So(se.SetStatusField("bad", func() {}), ShouldNotBeNil)
+	})
+
+	Convey("Static fields are merged into the status", t, func() {
+		So(se.SetStatusField("shard_id", 7), ShouldBeNil)
+		So(se.SetStatusField("features", []string{"checkout-v2"}), ShouldBeNil)
+		fields := status()
+		So(fields["shard_id"], ShouldEqual, 7)
+		So(fields["features"], ShouldResemble, []interface{}{"checkout-v2"})
+		So(fields["version"], ShouldEqual, "dev")
+	})
+
+	Convey("Providers are called for each request", t, func() {
+		role := "follower"
+		se.AddStatusProvider(func() (string, interface{}) { return "role", role })
+		So(status()["role"], ShouldEqual, "follower")
+		role = "leader"
+		So(status()["role"], ShouldEqual, "leader")
+	})
+
+	Convey("Providers can't replace SE4 or static fields", t, func() {
+		se.AddStatusProvider(func() (string, interface{}) { return "version", "hijacked" })
+		se.AddStatusProvider(func() (string, interface{}) { return "shard_id", 8 })
+		fields := status()
+		So(fields["version"], ShouldEqual, "dev")
+		So(fields["shard_id"], ShouldEqual, 7)
+	})
+}
@@ -19,8 +19,10 @@
 package se4
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"runtime"
@@ -29,7 +31,6 @@ import (
 
 	"time"
 
-	sigar "github.com/cloudfoundry/gosigar"
 	routing "github.com/go-ozzo/ozzo-routing"
 	"github.com/go-ozzo/ozzo-routing/content"
 )
@@ -50,17 +51,65 @@ const (
 // Optional
 // Name	         HTTP Verb	URI Path
 // Config	         GET	/service/config
+//
+// Additional
+// Name	         HTTP Verb	URI Path
+// Health Stream	 GET	/service/healthcheck/stream
+// Health Stats	 GET	/service/healthcheck/stats
+// Startup	         GET	/service/startup
+// Events	         GET	/service/events
 
 type StandardEndpoints struct {
 	Status       *Status
 	healthReport HealthCheckReport
 	locker       *sync.Mutex
 
-	healthChecks     []HealthCheckFunc
-	canaryCheck      ServiceCanaryFunc
-	gtgCheck         GoodToGoFunc
-	configSrc        ConfigSourceFunc
-	healthCheckTimer *time.Timer
+	healthChecks []HealthCheckFunc
+	canaryCheck  ServiceCanaryFunc
+	gtgCheck     GoodToGoFunc
+	configSrc    ConfigSourceFunc
+
+	healthCheckInterval time.Duration
+	stopScheduler       context.CancelFunc
+	schedulerDone       chan struct{}
+	schedulerParent     context.Context // the context given to Start, reused when SetHealthCheckFuncs restarts the checks
+	manualRuns          context.Context // for runs from RunHealthChecks, cancelled by Stop
+	cancelManualRuns    context.CancelFunc
+	closed              bool
+	stopped             bool // by Stop, until Start is called again
+
+	logger            *slog.Logger
+	healthListeners   []healthListener
+	nextListenerID    int
+	checkStates       map[string]string
+	overallState      string
+	probeStates       map[string]string
+	healthStream      *healthStream
+	checkHistory      map[string]*healthCheckHistory
+	probeOptions      ProbeOptions
+	gtgProbe          *probe
+	canaryProbe       *probe
+	draining          bool
+	warmUpTasks       []*warmUpTask
+	warmUpLocker      *sync.Mutex
+	runLocker         *sync.Mutex
+	checkNames        []string // the name each health check returned on its last run
+	schedulerPaused   bool
+	pausedChecks      map[string]bool
+	overrides         map[string]HealthCheckOverride
+	adminActions      []AdminAction
+	events            *eventLog
+	eventsAuth        AdminAuthFunc
+	stateFile         *stateFile
+	faultsEnabled     bool
+	faults            map[string]Fault
+	remediations      map[string]*remediation
+	quotaProcessors   bool
+	statusFields      map[string]interface{}
+	statusProviders   []StatusProviderFunc
+	diskMounts        []string
+	listenAddresses   []string
+	advertisedAddress string
 }
 
 type ReportDuration time.Duration
@@ -77,6 +126,8 @@ type HealthCheckResult struct {
 	Name           string    `json:"test_name"`       // The name of the test, a name that is meaningful to supporting engineers
 	Result         string    `json:"test_result"`     // The state of the test, may be "not_run", "running", "passed", "failed"
 	Timestamp      time.Time `json:"tested_at"`       // The time at which this test was executed
+
+	Details map[string]interface{} `json:"details,omitempty"` // ADDITIONAL - e.g. the "remediation" status
 }
 
 func DurationToMillis(duration time.Duration) float64 {
@@ -125,6 +176,11 @@ type BuildInfo struct {
 	RunbookURI string `json:"runbook_uri"`
 	// version number (baked in)
 	Version string `json:"version"`
+
+	// from debug.ReadBuildInfo, see RuntimeBuildInfo
+	GitDirty      bool              `json:"git_dirty"`                // ADDITIONAL - uncommitted changes when built
+	GoVersion     string            `json:"go_version,omitempty"`     // ADDITIONAL -
+	BuildSettings map[string]string `json:"build_settings,omitempty"` // ADDITIONAL - e.g. CGO_ENABLED, GOOS, GOARCH, -race
 }
 
 type Status struct {
@@ -138,6 +194,10 @@ type Status struct {
 	// maven group
 	GroupID string `json:"group_id"` // N/A - maven
 
+	// where it runs, see SetEnvironmentOptions
+	Environment *EnvironmentInfo  `json:"environment,omitempty"` // ADDITIONAL - at startup
+	Instance    *InstanceMetadata `json:"instance,omitempty"`    // ADDITIONAL - at startup, see SetMetadataProvider
+
 	// machine (baked in)
 	MachineName    string `json:"machine_name"`     // dynamic
 	OSArch         string `json:"os_arch"`          // dynamic
@@ -146,17 +206,31 @@ type Status struct {
 	OSNumProcessor string `json:"os_numprocessors"` // dynamic
 	OSVersion      string `json:"os_version"`       // dynamic
 
+	System  *SystemInfo  `json:"system"`  // ADDITIONAL - dynamic - per req, with the full load average
+	Network *NetworkInfo `json:"network"` // ADDITIONAL - dynamic - per req
+
 	// dynamic / at startup
 	UpDuration string `json:"up_duration"` // dynamic - per req
 	UpSince    string `json:"up_since"`    // at startup
 
-	// N/A
-	VMName    string `json:"vm_name"`    // N/A
-	VMVendor  string `json:"vm_vendor"`  // N/A
-	VMVersion string `json:"vm_version"` // N/A
+	// the go runtime
+	VMName    string `json:"vm_name"`    // at startup
+	VMVendor  string `json:"vm_vendor"`  // at startup
+	VMVersion string `json:"vm_version"` // at startup
 	// go additions
-	GoMaxProcs    string `json:"go_maxprocs"`    // ADDITIONAL -
-	GoNumRoutines string `json:"go_numroutines"` // ADDITIONAL - dynamic
+	GoMaxProcs    string        `json:"go_maxprocs"`      // ADDITIONAL -
+	GoNumRoutines string        `json:"go_numroutines"`   // ADDITIONAL - dynamic
+	Runtime       *RuntimeStats `json:"runtime"`          // ADDITIONAL - dynamic - per req
+	Process       *ProcessInfo  `json:"process"`          // ADDITIONAL - dynamic - per req
+	Cgroup        *CgroupInfo   `json:"cgroup,omitempty"` // ADDITIONAL - dynamic - per req, linux only
+
+	// lifecycle
+	LifecycleState string       `json:"lifecycle_state"`          // ADDITIONAL - dynamic, "running", "draining", "stopping" or "stopped"
+	DrainingSince  string       `json:"draining_since,omitempty"` // ADDITIONAL - dynamic
+	Maintenance    *Maintenance `json:"maintenance,omitempty"`    // ADDITIONAL - dynamic
+
+	PreviousRun  *PreviousRun `json:"previous_run,omitempty"`  // ADDITIONAL - at startup, with a state file
+	ActiveFaults []Fault      `json:"active_faults,omitempty"` // ADDITIONAL - dynamic, injected faults
 
 	UpSinceTime time.Time `json:"-"`
 }
@@ -171,10 +245,32 @@ func NewStandardEndpointsWithBuildInfo(buildInfo *BuildInfo) *StandardEndpoints
 		UpSince:   now.Format(time.RFC3339), UpSinceTime: now, MachineName: name,
 		OSArch: u.Machine, OSVersion: u.Release, OSName: u.Sysname,
 		CompilerVersion: runtime.Compiler,
-		OSNumProcessor:  strconv.Itoa(runtime.NumCPU()),
-		GoMaxProcs:      strconv.Itoa(runtime.GOMAXPROCS(-1))}
-
-	return &StandardEndpoints{Status: s, locker: &sync.Mutex{}}
+		VMName:          "go", VMVendor: "The Go Authors", VMVersion: runtime.Version(),
+		OSNumProcessor: strconv.Itoa(runtime.NumCPU()),
+		GoMaxProcs:     strconv.Itoa(runtime.GOMAXPROCS(-1)),
+		LifecycleState: LifecycleRunning}
+
+	se := &StandardEndpoints{Status: s, locker: &sync.Mutex{},
+		logger:       slog.Default(),
+		checkStates:  map[string]string{},
+		overallState: HealthResultNotRun,
+		probeStates:  map[string]string{},
+		healthStream: newHealthStream(),
+		checkHistory: map[string]*healthCheckHistory{},
+		gtgProbe:     newProbe(),
+		canaryProbe:  newProbe(),
+		warmUpLocker: &sync.Mutex{},
+		runLocker:    &sync.Mutex{},
+		pausedChecks: map[string]bool{},
+		overrides:    map[string]HealthCheckOverride{},
+		faults:       map[string]Fault{},
+		remediations: map[string]*remediation{},
+		statusFields: map[string]interface{}{},
+		diskMounts:   DefaultDiskMounts,
+		events:       newEventLog()}
+	se.manualRuns, se.cancelManualRuns = context.WithCancel(context.Background())
+	se.events.record(EventStartup, "started", buildInfo)
+	return se
 }
 
 func NewStandardEndpoints() *StandardEndpoints {
@@ -197,73 +293,185 @@ var (
 	DefaultHealthCheckInterval = time.Duration(10) * time.Second
 )
 
-// Set the health check functions to run at a specified interval
+// Set the health check functions to run at a specified interval, starting them if the endpoints aren't closed,
+// with the context given to Start if it was called. No funcs stops them.
+// It waits for a run in progress to finish like Stop, so it mustn't be called from a health check or listener.
 func (s *StandardEndpoints) SetHealthCheckFuncs(interval time.Duration, healthchecks ...HealthCheckFunc) {
+	// stop any existing run first, so you could pass in an empty array to stop it..
+	s.Stop()
+
 	s.locker.Lock()
-	defer s.locker.Unlock()
 	s.healthChecks = healthchecks
-	if s.healthCheckTimer != nil {
-		s.healthCheckTimer.Stop()
-	}
+	s.healthCheckInterval = interval
+	s.checkNames = nil
+	ctx := s.schedulerParent
+	s.locker.Unlock()
 
-	// stop any existing timers first, so you could pass in an empty array to stop it..
 	if len(healthchecks) == 0 {
 		return
 	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s.Start(ctx)
+}
 
-	// fire right away, then adjust to interval
-	s.healthCheckTimer = time.AfterFunc(1, func() {
-		report := HealthCheckReport{}
-		start := time.Now()
+// RunHealthChecks runs each health check once now and publishes the report, whether or not they are scheduled.
+// Returns ErrStopped after Stop, until Start is called, and ErrClosed after Close.
+func (s *StandardEndpoints) RunHealthChecks() error {
+	s.locker.Lock()
+	ctx := s.manualRuns
+	s.locker.Unlock()
+	return s.runHealthChecks(ctx)
+}
 
-		var results []HealthCheckResult
-		for _, chk := range healthchecks {
-			results = append(results, chk())
+// run each health check once and publish the report, the context is cancelled by Stop
+func (s *StandardEndpoints) runHealthChecks(ctx context.Context) error {
+	s.runLocker.Lock()
+	defer s.runLocker.Unlock()
+
+	s.locker.Lock()
+	if s.closed {
+		s.locker.Unlock()
+		return ErrClosed
+	}
+	if s.stopped {
+		s.locker.Unlock()
+		return ErrStopped
+	}
+	healthchecks, names := s.healthChecks, s.checkNames
+	paused := make(map[string]bool, len(s.pausedChecks))
+	for name := range s.pausedChecks {
+		paused[name] = true
+	}
+	s.locker.Unlock()
+
+	report := HealthCheckReport{}
+	start := time.Now()
+
+	var results []HealthCheckResult
+	for i, chk := range healthchecks {
+		// a check's name is only known once it has run
+		if i < len(names) && paused[names[i]] {
+			results = append(results, HealthCheckResult{Name: names[i], Result: HealthResultNotRun, Timestamp: start})
+			continue
 		}
-		report.Results = results
-		report.Duration = ReportDuration(time.Since(start))
-		report.Timestamp = time.Now().UTC()
+		results = append(results, chk())
+	}
+	report.Duration = ReportDuration(time.Since(start))
+	report.Timestamp = time.Now().UTC()
+
+	s.locker.Lock()
+	s.checkNames = make([]string, len(results))
+	for i, result := range results {
+		s.checkNames[i] = result.Name
+	}
+	// stats and remediation go by what the checks found, not what they are overridden or faulted to,
+	// and the warm-up tasks aren't runs of a check
+	s.recordHealthStats(results, report.Timestamp)
+	due := s.remediationsDue(results, report.Timestamp)
+	report.Results = append(results, s.warmUpResults()...)
+	s.healthReport = report
+	s.locker.Unlock()
+
+	// notify before scheduling the next run so listeners see transitions in order
+	s.publishHealthReport()
+	s.runRemediations(ctx, due)
+	if err := s.writeState(false); err != nil {
+		s.log("writing state file failed", "error", err.Error())
+	}
+	return nil
+}
+
+// the last report with the unexpired overrides and check faults applied, forgetting those that have expired.
+// must be called with the lock held
+func (s *StandardEndpoints) effectiveReport(now time.Time) HealthCheckReport {
+	report := s.healthReport
+	report.Results = append([]HealthCheckResult(nil), s.healthReport.Results...)
+	s.applyOverrides(report.Results, now)
+	s.applyFaults(report.Results)
+	return report
+}
 
-		s.locker.Lock()
+// the current report, notifying listeners and the stream of any change since it was last published,
+// so overrides and faults take effect and expire without waiting for the next run
+func (s *StandardEndpoints) publishHealthReport() HealthCheckReport {
+	s.locker.Lock()
+	if s.healthReport.Timestamp.IsZero() {
 		defer s.locker.Unlock()
-		s.healthReport = report
-		s.healthCheckTimer.Reset(interval)
-	})
+		return s.healthReport // nothing has run yet
+	}
+	now := time.Now().UTC()
+	report := s.effectiveReport(now)
+	transitions := s.healthTransitions(report, now)
+	s.locker.Unlock()
+
+	s.notifyHealthTransitions(transitions)
+	if len(transitions) > 0 {
+		s.healthStream.publish(report)
+	}
+	return report
 }
 
 func (s *StandardEndpoints) SetServiceCanaryFunc(canaryCheck ServiceCanaryFunc) {
 	s.locker.Lock()
 	defer s.locker.Unlock()
 	s.canaryCheck = canaryCheck
+	s.canaryProbe.reset()
 }
 
 func (s *StandardEndpoints) SetGoodToGoFunc(gtgCheck GoodToGoFunc) {
 	s.locker.Lock()
 	defer s.locker.Unlock()
 	s.gtgCheck = gtgCheck
+	s.gtgProbe.reset()
 }
 
 func (s *StandardEndpoints) SetConfigSourceFunc(configSrc ConfigSourceFunc) {
 	s.locker.Lock()
-	defer s.locker.Unlock()
 	s.configSrc = configSrc
+	s.locker.Unlock()
+	s.events.record(EventConfigSource, "config source changed", nil)
 }
 
 func (s *StandardEndpoints) generateStatus() *Status {
+	// read the system before taking the lock, so a hung mount or slow /proc doesn't block the probes & health checks
+	s.locker.Lock()
+	mounts := s.diskMounts
+	s.locker.Unlock()
+	system := readSystemInfo(mounts)
+	runtimeStats := readRuntimeStats()
+	process := readProcessInfo()
+	cgroup, err := ReadCgroupInfo(DefaultCgroupRoot, DefaultProcSelf)
+	if err != nil {
+		cgroup = nil
+	}
+	interfaces, outbound := interfaceAddresses(), outboundAddress(DefaultOutboundProbeAddress)
+
 	s.locker.Lock()
 	defer s.locker.Unlock()
-	concreteSigar := sigar.ConcreteSigar{}
-	avg, err := concreteSigar.GetLoadAverage()
+	s.Status.System = system
 	s.Status.OSAvgload = "0.0"
-	if err == nil {
-		s.Status.OSAvgload = fmt.Sprintf("%.2f", avg.One)
+	if s.Status.System.LoadAverage != nil {
+		s.Status.OSAvgload = fmt.Sprintf("%.2f", s.Status.System.LoadAverage.One)
 	}
 	now := time.Now().UTC()
 	s.Status.UpDuration = now.Sub(s.Status.UpSinceTime).String()
 	s.Status.CurrentTime = now.Format(time.RFC3339)
 	s.Status.GoNumRoutines = strconv.Itoa(runtime.NumGoroutine())
+	s.Status.Runtime = runtimeStats
+	s.Status.Process = process
+	s.Status.Cgroup = cgroup
+	s.Status.OSNumProcessor = strconv.Itoa(s.numProcessors())
+	s.Status.Network = s.networkInfo(interfaces, outbound)
+	s.Status.ActiveFaults = nil
+	if faults := s.activeFaults(now); len(faults) > 0 {
+		s.Status.ActiveFaults = faults
+	}
 
-	return s.Status
+	// copy so the response can be written without holding the lock
+	status := *s.Status
+	return &status
 }
 
 func (s *StandardEndpoints) RegisterDefaultEndpoints(router *routing.Router) {
@@ -277,10 +485,15 @@ func CreateDefaultRouterGroup(router *routing.Router) *routing.RouteGroup {
 func (s *StandardEndpoints) RegisterEndpoints(group *routing.RouteGroup) {
 	group.Use(
 		content.TypeNegotiator(routing.MIME_JSON),
+		s.faultLatency,
 	)
 
 	group.Get("/status", func(c *routing.Context) error {
-		c.Write(s.generateStatus())
+		status, err := s.statusJSON(s.generateStatus())
+		if err != nil {
+			return err
+		}
+		c.Write(status)
 		return nil
 	})
 
@@ -292,22 +505,28 @@ func (s *StandardEndpoints) RegisterEndpoints(group *routing.RouteGroup) {
 	})
 
 	group.Get("/healthcheck", func(c *routing.Context) error {
-		s.locker.Lock()
-		defer s.locker.Unlock()
+		report := s.publishHealthReport()
 		c.Response.WriteHeader(http.StatusOK)
-		c.Write(s.healthReport)
+		c.Write(report)
 		return nil
 	})
 
+	group.Get("/healthcheck/stream", s.serveHealthStream)
+
+	group.Get("/startup", s.serveStartup)
+
+	group.Get("/events", s.serveEvents)
+
+	group.Get("/healthcheck/stats", func(c *routing.Context) error {
+		return c.Write(s.HealthCheckStats())
+	})
+
 	textDataWriter := &TextPlainDataWriter{}
 
 	// successful response is a 200 OK with a content of the text "OK" (including quotes) and a media type of "plain/text"
 	// failed response is a 5XX response with either a 500 or 503 response preferred.
 	group.Get("/healthcheck/gtg", func(c *routing.Context) error {
-		result := true
-		if s.gtgCheck != nil {
-			result = s.gtgCheck()
-		}
+		result, reason := s.evaluateGoodToGo()
 
 		textDataWriter.SetHeader(c.Response)
 		c.SetDataWriter(textDataWriter)
@@ -316,6 +535,9 @@ func (s *StandardEndpoints) RegisterEndpoints(group *routing.RouteGroup) {
 			c.Write("OK") // if we're here, we're good (for now)
 		} else {
 			c.Response.WriteHeader(http.StatusServiceUnavailable)
+			if reason != "" {
+				c.Write(reason)
+			}
 		}
 		return nil
 	})
@@ -325,10 +547,7 @@ func (s *StandardEndpoints) RegisterEndpoints(group *routing.RouteGroup) {
 	group.Get("/healthcheck/asg", func(c *routing.Context) error {
 		c.SetDataWriter(&content.HTMLDataWriter{})
 
-		result := true
-		if s.canaryCheck != nil {
-			result = s.canaryCheck()
-		}
+		result := s.evaluateServiceCanary()
 
 		textDataWriter.SetHeader(c.Response)
 		c.SetDataWriter(textDataWriter)
@@ -355,3 +574,13 @@ func (w *TextPlainDataWriter) Write(res http.ResponseWriter, data interface{}) e
 func (r ReportDuration) MarshalJSON() ([]byte, error) {
 	return json.Marshal(time.Duration(r).String())
 }
+
+func (r *ReportDuration) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	d, err := time.ParseDuration(str)
+	*r = ReportDuration(d)
+	return err
+}
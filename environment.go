@@ -0,0 +1,144 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	// the downward API volume with "labels" & "annotations" files
+	DefaultPodInfoDir = "/etc/podinfo"
+	// the namespace of the pod when it isn't in the environment
+	DefaultServiceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+	// the label & annotation keys included by default, as path.Match patterns
+	DefaultEnvironmentAllowlist = []string{"app", "version", "team", "app.kubernetes.io/*"}
+
+	// the environment variables for each field, the first one set wins
+	DefaultEnvironmentVars = map[string][]string{
+		"pod_name":    {"POD_NAME", "K8S_POD_NAME"},
+		"namespace":   {"POD_NAMESPACE", "K8S_NAMESPACE"},
+		"node_name":   {"NODE_NAME", "K8S_NODE_NAME"},
+		"pod_ip":      {"POD_IP"},
+		"region":      {"REGION", "CLOUD_REGION", "AWS_REGION", "GOOGLE_CLOUD_REGION"},
+		"zone":        {"ZONE", "CLOUD_ZONE", "AVAILABILITY_ZONE"},
+		"cluster":     {"CLUSTER", "CLUSTER_NAME", "K8S_CLUSTER_NAME"},
+		"environment": {"ENVIRONMENT", "DEPLOY_ENV", "APP_ENV", "ENV"},
+	}
+)
+
+// EnvironmentInfo is where the instance runs.
+type EnvironmentInfo struct {
+	PodName     string            `json:"pod_name,omitempty"`
+	Namespace   string            `json:"namespace,omitempty"`
+	NodeName    string            `json:"node_name,omitempty"`
+	PodIP       string            `json:"pod_ip,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Region      string            `json:"region,omitempty"`
+	Zone        string            `json:"zone,omitempty"`
+	Cluster     string            `json:"cluster,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+}
+
+// EnvironmentOptions controls where the environment metadata is read from.
+type EnvironmentOptions struct {
+	PodInfoDir    string   // DefaultPodInfoDir if empty
+	NamespaceFile string   // DefaultServiceAccountNamespaceFile if empty
+	Allowlist     []string // the label & annotation keys to include, DefaultEnvironmentAllowlist if nil
+	Getenv        func(key string) string
+}
+
+// Read the environment metadata & show it in the status as "environment", call again to refresh it.
+func (s *StandardEndpoints) SetEnvironmentOptions(opts EnvironmentOptions) {
+	env := ReadEnvironmentInfo(opts)
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.Status.Environment = env
+}
+
+// ReadEnvironmentInfo reads the kubernetes downward API environment variables & files, and the common
+// variables for the region, zone, cluster and environment.
+func ReadEnvironmentInfo(opts EnvironmentOptions) *EnvironmentInfo {
+	if opts.PodInfoDir == "" {
+		opts.PodInfoDir = DefaultPodInfoDir
+	}
+	if opts.NamespaceFile == "" {
+		opts.NamespaceFile = DefaultServiceAccountNamespaceFile
+	}
+	if opts.Allowlist == nil {
+		opts.Allowlist = DefaultEnvironmentAllowlist
+	}
+	if opts.Getenv == nil {
+		opts.Getenv = os.Getenv
+	}
+
+	lookup := func(field string) string {
+		for _, name := range DefaultEnvironmentVars[field] {
+			if value := opts.Getenv(name); value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+	env := &EnvironmentInfo{
+		PodName: lookup("pod_name"), Namespace: lookup("namespace"), NodeName: lookup("node_name"),
+		PodIP: lookup("pod_ip"), Region: lookup("region"), Zone: lookup("zone"),
+		Cluster: lookup("cluster"), Environment: lookup("environment"),
+	}
+	if env.Namespace == "" {
+		env.Namespace = readString(opts.NamespaceFile)
+	}
+	env.Labels = readDownwardAPIFile(filepath.Join(opts.PodInfoDir, "labels"), opts.Allowlist)
+	env.Annotations = readDownwardAPIFile(filepath.Join(opts.PodInfoDir, "annotations"), opts.Allowlist)
+	return env
+}
+
+// the allowed keys of a downward API file, which has a key="quoted value" per line
+func readDownwardAPIFile(file string, allowlist []string) map[string]string {
+	var values map[string]string
+	for _, line := range readLines(file) {
+		key, quoted, found := strings.Cut(line, "=")
+		if !found || !allowed(key, allowlist) {
+			continue
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			value = quoted
+		}
+		if values == nil {
+			values = map[string]string{}
+		}
+		values[key] = value
+	}
+	return values
+}
+
+func allowed(key string, allowlist []string) bool {
+	for _, pattern := range allowlist {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEnvironmentInfo(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "labels"),
+		[]byte("app=\"checkout\"\napp.kubernetes.io/version=\"1.2.3\"\npod-template-hash=\"5d8f\"\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "annotations"),
+		[]byte("team=\"payments\"\nkubectl.kubernetes.io/last-applied-configuration=\"{\\\"big\\\":true}\"\n"), 0644)
+	namespaceFile := filepath.Join(dir, "namespace")
+	os.WriteFile(namespaceFile, []byte("shop\n"), 0644)
+
+	env := map[string]string{"POD_NAME": "checkout-5d8f-abcde", "NODE_NAME": "node-7", "POD_IP": "10.1.2.3",
+		"AWS_REGION": "eu-west-1", "AVAILABILITY_ZONE": "eu-west-1a", "CLUSTER_NAME": "prod-eu", "ENVIRONMENT": "production"}
+	opts := EnvironmentOptions{PodInfoDir: dir, NamespaceFile: namespaceFile, Getenv: func(key string) string { return env[key] }}
+
+	Convey("The downward API and common variables are read", t, func() {
+		info := ReadEnvironmentInfo(opts)
+		So(info, ShouldResemble, &EnvironmentInfo{
+			PodName: "checkout-5d8f-abcde", Namespace: "shop", NodeName: "node-7", PodIP: "10.1.2.3",
+			Labels:      map[string]string{"app": "checkout", "app.kubernetes.io/version": "1.2.3"},
+			Annotations: map[string]string{"team": "payments"},
+			Region:      "eu-west-1", Zone: "eu-west-1a", Cluster: "prod-eu", Environment: "production"})
+	})
+
+	Convey("The allowlist is configurable", t, func() {
+		opts := opts
+		opts.Allowlist = []string{"pod-template-hash", "kubectl.kubernetes.io/*"}
+		info := ReadEnvironmentInfo(opts)
+		So(info.Labels, ShouldResemble, map[string]string{"pod-template-hash": "5d8f"})
+		So(info.Annotations, ShouldResemble, map[string]string{"kubectl.kubernetes.io/last-applied-configuration": `{"big":true}`})
+	})
+
+	Convey("The namespace variable wins over the service account file", t, func() {
+		env["POD_NAMESPACE"] = "shop-canary"
+		So(ReadEnvironmentInfo(opts).Namespace, ShouldEqual, "shop-canary")
+		delete(env, "POD_NAMESPACE")
+	})
+
+	Convey("The environment is shown in the status", t, func() {
+		se := NewStandardEndpoints()
+		So(se.generateStatus().Environment, ShouldBeNil)
+		se.SetEnvironmentOptions(opts)
+		So(se.generateStatus().Environment.PodName, ShouldEqual, "checkout-5d8f-abcde")
+	})
+}
@@ -0,0 +1,178 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	routing "github.com/go-ozzo/ozzo-routing"
+)
+
+// EventType
+// "startup", "health_transition", "lifecycle", "maintenance", "config_source", "admin_action"
+const (
+	EventStartup          = "startup"
+	EventHealthTransition = "health_transition"
+	EventLifecycle        = "lifecycle" // draining, stopping and stopped
+	EventMaintenance      = "maintenance"
+	EventConfigSource     = "config_source"
+	EventAdminAction      = "admin_action"
+)
+
+var (
+	DefaultEventLogSize   = 1000 // the number of events kept in memory
+	DefaultEventPageLimit = 100
+	MaxEventPageLimit     = 1000
+)
+
+// Event is an entry in the operational event log.
+type Event struct {
+	ID        uint64      `json:"id"` // increases by one for each event
+	Type      string      `json:"type"`
+	Message   string      `json:"message"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+// EventPage is a page of events served by /service/events.
+type EventPage struct {
+	Events  []Event `json:"events"`
+	Next    uint64  `json:"next"`     // pass as ?after= to get the next page
+	HasMore bool    `json:"has_more"` // false once the end of the log is reached
+}
+
+// eventLog is bounded, dropping the oldest events first, and has its own lock so events can be
+// recorded whether or not the StandardEndpoints lock is held.
+type eventLog struct {
+	locker *sync.Mutex
+	lastID uint64
+	events []Event
+	export *os.File
+}
+
+func newEventLog() *eventLog {
+	return &eventLog{locker: &sync.Mutex{}}
+}
+
+func (l *eventLog) record(eventType, message string, data interface{}) {
+	l.locker.Lock()
+	defer l.locker.Unlock()
+	l.lastID++
+	event := Event{ID: l.lastID, Type: eventType, Message: message, Timestamp: time.Now().UTC(), Data: data}
+	l.events = append(l.events, event)
+	if len(l.events) > DefaultEventLogSize {
+		l.events = append(l.events[:0:0], l.events[len(l.events)-DefaultEventLogSize:]...)
+	}
+	if l.export != nil {
+		if line, err := json.Marshal(event); err == nil {
+			l.export.Write(append(line, '\n'))
+		}
+	}
+}
+
+// the events after the id, at most limit of them
+func (l *eventLog) page(after uint64, limit int) EventPage {
+	l.locker.Lock()
+	defer l.locker.Unlock()
+	page := EventPage{Events: []Event{}, Next: after}
+	for _, e := range l.events {
+		if e.ID <= after {
+			continue
+		}
+		if len(page.Events) == limit {
+			page.HasMore = true
+			break
+		}
+		page.Events = append(page.Events, e)
+		page.Next = e.ID
+	}
+	return page
+}
+
+func (l *eventLog) exportTo(f *os.File) {
+	l.locker.Lock()
+	defer l.locker.Unlock()
+	if l.export != nil {
+		l.export.Close()
+	}
+	l.export = f
+}
+
+// Events returns up to limit events recorded after the given id, oldest first. Use an id of 0 to start at the beginning.
+func (s *StandardEndpoints) Events(after uint64, limit int) EventPage {
+	return s.events.page(after, limit)
+}
+
+// Record an application specific event in the event log.
+func (s *StandardEndpoints) RecordEvent(eventType, message string, data interface{}) {
+	s.events.record(eventType, message, data)
+}
+
+// Append each new event as a line of JSON to the file, creating it if needed.
+// An empty path stops the export.
+func (s *StandardEndpoints) ExportEvents(path string) error {
+	if path == "" {
+		s.events.exportTo(nil)
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	s.events.exportTo(f)
+	return nil
+}
+
+// Require requests to /service/events to be authenticated, nil serves it to everyone which is the default.
+// Admin actions are recorded without who made them either way, they are in the admin audit log.
+func (s *StandardEndpoints) SetEventsAuth(auth AdminAuthFunc) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.eventsAuth = auth
+}
+
+// ?after=<id>&limit=<n>
+func (s *StandardEndpoints) serveEvents(c *routing.Context) error {
+	s.locker.Lock()
+	auth := s.eventsAuth
+	s.locker.Unlock()
+	if auth != nil {
+		if _, ok := auth(c.Request); !ok {
+			c.Response.Header().Set("WWW-Authenticate", `Basic realm="se4 events"`)
+			return routing.NewHTTPError(http.StatusUnauthorized)
+		}
+	}
+	after, err := strconv.ParseUint(c.Query("after", "0"), 10, 64)
+	if err != nil {
+		return routing.NewHTTPError(http.StatusBadRequest, "after must be an event id")
+	}
+	limit, err := strconv.Atoi(c.Query("limit", strconv.Itoa(DefaultEventPageLimit)))
+	if err != nil || limit < 1 {
+		return routing.NewHTTPError(http.StatusBadRequest, "limit must be a positive number")
+	}
+	if limit > MaxEventPageLimit {
+		limit = MaxEventPageLimit
+	}
+	return c.Write(s.Events(after, limit))
+}
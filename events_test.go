@@ -0,0 +1,153 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-ozzo/ozzo-routing"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEvents(t *testing.T) {
+	r := routing.New()
+	se := NewStandardEndpoints()
+	se.SetLogger(nil)
+	se.RegisterDefaultEndpoints(r)
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	Convey("Events can be exported to a file", t, func() {
+		So(se.ExportEvents(path), ShouldBeNil)
+	})
+
+	se.StartMaintenance("upgrade", time.Time{})
+	se.EndMaintenance()
+	se.SetConfigSourceFunc(func() interface{} { return nil })
+	se.Drain()
+	se.healthChecks = []HealthCheckFunc{func() HealthCheckResult {
+		return HealthCheckResult{Name: "db", Result: HealthResultPassed}
+	}}
+	se.RunHealthChecks()
+	se.recordAdminAction(AdminAction{User: "ops", Action: "pause"})
+
+	get := func(path string) (*httptest.ResponseRecorder, EventPage) {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(res, req)
+		var page EventPage
+		json.Unmarshal(res.Body.Bytes(), &page)
+		return res, page
+	}
+
+	Convey("Operational events are recorded in order", t, func() {
+		res, page := get("/service/events")
+		So(res.Code, ShouldEqual, http.StatusOK)
+		var types []string
+		for _, e := range page.Events {
+			types = append(types, e.Type)
+		}
+		So(types, ShouldResemble, []string{EventStartup, EventMaintenance, EventMaintenance, EventConfigSource,
+			EventLifecycle, EventHealthTransition, EventHealthTransition, EventAdminAction})
+		So(page.Events[4].Message, ShouldEqual, "running -> draining")
+		So(page.Events[5].Message, ShouldEqual, "check db not_run -> passed")
+		So(page.Events[6].Message, ShouldEqual, "overall not_run -> passed")
+		So(page.Events[7].Message, ShouldEqual, "pause")
+		So(res.Body.String(), ShouldNotContainSubstring, "ops")
+		So(page.HasMore, ShouldBeFalse)
+		So(page.Next, ShouldEqual, 8)
+	})
+
+	Convey("Events are paginated", t, func() {
+		_, page := get("/service/events?limit=3")
+		So(len(page.Events), ShouldEqual, 3)
+		So(page.HasMore, ShouldBeTrue)
+		So(page.Next, ShouldEqual, 3)
+
+		_, page = get("/service/events?after=3&limit=3")
+		So(page.Events[0].ID, ShouldEqual, 4)
+		So(page.Next, ShouldEqual, 6)
+
+		_, page = get("/service/events?after=8")
+		So(page.Events, ShouldBeEmpty)
+		So(page.Next, ShouldEqual, 8)
+	})
+
+	Convey("Events can require authentication", t, func() {
+		se.SetEventsAuth(BasicAuth(map[string]string{"alice": "secret"}))
+		defer se.SetEventsAuth(nil)
+		res, _ := get("/service/events")
+		So(res.Code, ShouldEqual, http.StatusUnauthorized)
+
+		res = httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/service/events", nil)
+		req.SetBasicAuth("alice", "secret")
+		r.ServeHTTP(res, req)
+		So(res.Code, ShouldEqual, http.StatusOK)
+	})
+
+	Convey("Bad pagination parameters are rejected", t, func() {
+		res, _ := get("/service/events?after=x")
+		So(res.Code, ShouldEqual, http.StatusBadRequest)
+		res, _ = get("/service/events?limit=0")
+		So(res.Code, ShouldEqual, http.StatusBadRequest)
+	})
+
+	Convey("Exported events are written as JSON lines", t, func() {
+		se.Close()
+		f, err := os.Open(path)
+		So(err, ShouldBeNil)
+		defer f.Close()
+		var lines []Event
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var e Event
+			So(json.Unmarshal(scanner.Bytes(), &e), ShouldBeNil)
+			lines = append(lines, e)
+		}
+		// the startup event came before the export was set up
+		So(len(lines), ShouldEqual, 7)
+		So(lines[0].Type, ShouldEqual, EventMaintenance)
+		So(lines[6].Type, ShouldEqual, EventAdminAction)
+	})
+}
+
+func TestEventLogIsBounded(t *testing.T) {
+	size := DefaultEventLogSize
+	DefaultEventLogSize = 3
+	defer func() { DefaultEventLogSize = size }()
+
+	l := newEventLog()
+	for i := 0; i < 5; i++ {
+		l.record(EventStartup, "started", nil)
+	}
+
+	Convey("The oldest events are dropped", t, func() {
+		page := l.page(0, 10)
+		So(len(page.Events), ShouldEqual, 3)
+		So(page.Events[0].ID, ShouldEqual, 3)
+		So(page.Next, ShouldEqual, 5)
+	})
+}
@@ -37,7 +37,7 @@ func main() {
 
 	bi := &se4.BuildInfo{Version: Version, BuiltBy: "me"}
 
-	se := se4.NewStandardEndpointsWithBuildInfo(bi)
+	se := se4.NewStandardEndpointsWithRuntimeBuildInfo(bi)
 	se.RegisterDefaultEndpoints(router)
 
 	se.SetHealthCheckFuncs(time.Duration(10)*time.Second,
@@ -87,7 +87,6 @@ func main() {
 		"port": port,
 	}))
 
-	http.Handle("/", router)
 	fmt.Printf("listening on: %v\n", port)
 	fmt.Printf("now you can run: \n")
 	fmt.Printf("curl -v http://localhost:8080/service/status\n")
@@ -95,5 +94,10 @@ func main() {
 	fmt.Printf("curl -v http://localhost:8080/service/healthcheck\n")
 	fmt.Printf("curl -v http://localhost:8080/service/healthcheck/gtg\n")
 	fmt.Printf("curl -v http://localhost:8080/service/healthcheck/asg\n")
-	http.ListenAndServe(":"+port, nil)
+
+	// on SIGTERM fail gtg for the drain period before stopping the server
+	server := se4.NewGracefulServer(&http.Server{Addr: ":" + port, Handler: router}, se)
+	if err := server.ListenAndServe(); err != nil {
+		fmt.Printf("server error: %v\n", err)
+	}
 }
@@ -0,0 +1,235 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	routing "github.com/go-ozzo/ozzo-routing"
+)
+
+//
+// Fault injection, registered by RegisterAdminEndpoints, only works once enabled with SetFaultInjectionEnabled
+//
+// Name	                 HTTP Verb	URI Path
+// Active Faults	         GET	/service/admin/faults
+// Inject Fault	         POST	/service/admin/faults?kind=gtg&duration=5m
+// Inject Check Fault	     POST	/service/admin/faults?kind=check&name=db&duration=5m
+// Inject Latency	         POST	/service/admin/faults?kind=latency&latency=2s&duration=5m
+// Clear Fault	         DELETE	/service/admin/faults?kind=check&name=db
+// Clear All Faults	     DELETE	/service/admin/faults
+
+// FaultKind
+// "gtg", "asg", "check", "latency"
+const (
+	FaultGoodToGo = "gtg"     // GTG fails
+	FaultCanary   = "asg"     // the service canary fails
+	FaultCheck    = "check"   // the named health check fails
+	FaultLatency  = "latency" // the /service handlers are delayed
+)
+
+const EventFaultInjection = "fault_injection"
+
+var (
+	ErrFaultInjectionDisabled = errors.New("se4: fault injection is not enabled")
+)
+
+// Fault makes the instance act unhealthy until it expires.
+type Fault struct {
+	Kind    string         `json:"kind"`
+	Name    string         `json:"name,omitempty"`    // the check name for a "check" fault
+	Latency ReportDuration `json:"latency,omitempty"` // the delay for a "latency" fault
+	Until   time.Time      `json:"until"`
+	SetBy   string         `json:"set_by,omitempty"`
+	SetWhen time.Time      `json:"set_when"`
+}
+
+func (f Fault) key() string {
+	return f.Kind + "/" + f.Name
+}
+
+// Faults can't be injected unless enabled, so it has to be a deliberate choice for the deployment.
+func (s *StandardEndpoints) SetFaultInjectionEnabled(enabled bool) {
+	s.locker.Lock()
+	s.faultsEnabled = enabled
+	if !enabled {
+		s.faults = map[string]Fault{}
+	}
+	s.locker.Unlock()
+}
+
+// Inject a fault that lasts for the duration, replacing one of the same kind & name.
+func (s *StandardEndpoints) InjectFault(fault Fault, duration time.Duration) error {
+	switch fault.Kind {
+	case FaultGoodToGo, FaultCanary:
+		fault.Name = ""
+	case FaultCheck:
+		if fault.Name == "" {
+			return fmt.Errorf("a check fault needs the check name")
+		}
+	case FaultLatency:
+		fault.Name = ""
+		if fault.Latency <= 0 {
+			return fmt.Errorf("a latency fault needs a positive latency")
+		}
+	default:
+		return fmt.Errorf("unknown fault kind %q", fault.Kind)
+	}
+	if duration <= 0 {
+		return fmt.Errorf("fault duration must be positive")
+	}
+	now := time.Now().UTC()
+	fault.SetWhen, fault.Until = now, now.Add(duration)
+
+	s.locker.Lock()
+	if !s.faultsEnabled {
+		s.locker.Unlock()
+		return ErrFaultInjectionDisabled
+	}
+	s.faults[fault.key()] = fault
+	s.locker.Unlock()
+
+	s.log("fault injected", "kind", fault.Kind, "name", fault.Name, "until", fault.Until)
+	public := fault
+	public.SetBy = "" // the event log may be public
+	s.events.record(EventFaultInjection, "fault injected", public)
+	s.publishHealthReport()
+	return nil
+}
+
+// Clear the fault of the kind & name, the name is empty except for check faults.
+func (s *StandardEndpoints) ClearFault(kind, name string) {
+	s.locker.Lock()
+	_, found := s.faults[Fault{Kind: kind, Name: name}.key()]
+	delete(s.faults, Fault{Kind: kind, Name: name}.key())
+	s.locker.Unlock()
+	if found {
+		s.events.record(EventFaultInjection, "fault cleared", Fault{Kind: kind, Name: name})
+		s.publishHealthReport()
+	}
+}
+
+func (s *StandardEndpoints) ClearFaults() {
+	s.locker.Lock()
+	found := len(s.faults) > 0
+	s.faults = map[string]Fault{}
+	s.locker.Unlock()
+	if found {
+		s.events.record(EventFaultInjection, "faults cleared", nil)
+		s.publishHealthReport()
+	}
+}
+
+// Faults returns the active faults, ordered by kind & name.
+func (s *StandardEndpoints) Faults() []Fault {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return s.activeFaults(time.Now())
+}
+
+// the unexpired faults, dropping the expired ones, must be called with the lock held
+func (s *StandardEndpoints) activeFaults(now time.Time) []Fault {
+	faults := []Fault{}
+	for key, f := range s.faults {
+		if now.Before(f.Until) {
+			faults = append(faults, f)
+		} else {
+			delete(s.faults, key)
+		}
+	}
+	sort.Slice(faults, func(i, j int) bool { return faults[i].key() < faults[j].key() })
+	return faults
+}
+
+// the active fault of the kind & name, must be called with the lock held
+func (s *StandardEndpoints) activeFault(kind, name string) (Fault, bool) {
+	f, ok := s.faults[Fault{Kind: kind, Name: name}.key()]
+	if ok && !time.Now().Before(f.Until) {
+		delete(s.faults, f.key())
+		return f, false
+	}
+	return f, ok
+}
+
+// fail the results that have a check fault, must be called with the lock held
+func (s *StandardEndpoints) applyFaults(results []HealthCheckResult) {
+	for i := range results {
+		if _, ok := s.activeFault(FaultCheck, results[i].Name); ok {
+			results[i].Result = HealthResultFailed
+		}
+	}
+}
+
+// delay the request when there is a latency fault
+func (s *StandardEndpoints) faultLatency(c *routing.Context) error {
+	s.locker.Lock()
+	f, ok := s.activeFault(FaultLatency, "")
+	s.locker.Unlock()
+	if !ok {
+		return nil
+	}
+	select {
+	case <-time.After(time.Duration(f.Latency)):
+	case <-c.Request.Context().Done():
+	}
+	return nil
+}
+
+func (s *StandardEndpoints) registerFaultEndpoints(admin *routing.RouteGroup) {
+	admin.Get("/faults", func(c *routing.Context) error {
+		return c.Write(s.Faults())
+	})
+	admin.Post("/faults", func(c *routing.Context) error {
+		fault := Fault{Kind: c.Query("kind"), Name: c.Query("name"), SetBy: c.Get(adminUserKey).(string)}
+		duration, err := time.ParseDuration(c.Query("duration"))
+		if err != nil {
+			return routing.NewHTTPError(http.StatusBadRequest, "invalid duration: "+err.Error())
+		}
+		if fault.Kind == FaultLatency {
+			latency, err := time.ParseDuration(c.Query("latency"))
+			if err != nil {
+				return routing.NewHTTPError(http.StatusBadRequest, "invalid latency: "+err.Error())
+			}
+			fault.Latency = ReportDuration(latency)
+		}
+		if err := s.InjectFault(fault, duration); err == ErrFaultInjectionDisabled {
+			return routing.NewHTTPError(http.StatusForbidden, err.Error())
+		} else if err != nil {
+			return routing.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		s.recordAdminAction(AdminAction{User: fault.SetBy, Action: "inject fault", Target: fault.Name,
+			Detail: fault.Kind + " for " + duration.String(), Timestamp: time.Now().UTC()})
+		return c.Write(s.Faults())
+	})
+	admin.Delete("/faults", func(c *routing.Context) error {
+		kind := c.Query("kind")
+		if kind == "" {
+			s.ClearFaults()
+		} else {
+			s.ClearFault(kind, c.Query("name"))
+		}
+		s.recordAdminAction(AdminAction{User: c.Get(adminUserKey).(string), Action: "clear fault", Target: c.Query("name"),
+			Detail: kind, Timestamp: time.Now().UTC()})
+		return c.Write(s.Faults())
+	})
+}
@@ -0,0 +1,139 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-ozzo/ozzo-routing"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFaultInjection(t *testing.T) {
+	r := routing.New()
+	se := NewStandardEndpoints()
+	se.SetLogger(nil)
+	se.RegisterDefaultEndpoints(r)
+	se.RegisterKubernetesEndpoints(&r.RouteGroup)
+	se.RegisterAdminEndpoints(CreateDefaultRouterGroup(r), BasicAuth(map[string]string{"alice": "secret"}))
+	se.healthChecks = []HealthCheckFunc{
+		func() HealthCheckResult { return HealthCheckResult{Name: "db", Result: HealthResultPassed} },
+		func() HealthCheckResult { return HealthCheckResult{Name: "cache", Result: HealthResultPassed} },
+	}
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		req.SetBasicAuth("alice", "secret")
+		r.ServeHTTP(res, req)
+		return res
+	}
+
+	Convey("Faults can't be injected until enabled", t, func() {
+		So(se.InjectFault(Fault{Kind: FaultGoodToGo}, time.Minute), ShouldEqual, ErrFaultInjectionDisabled)
+		So(serve("POST", "/service/admin/faults?kind=gtg&duration=1m").Code, ShouldEqual, http.StatusForbidden)
+		So(serve("GET", "/service/healthcheck/gtg").Code, ShouldEqual, http.StatusOK)
+	})
+
+	se.SetFaultInjectionEnabled(true)
+
+	Convey("Invalid faults are rejected", t, func() {
+		So(serve("POST", "/service/admin/faults?kind=disk&duration=1m").Code, ShouldEqual, http.StatusBadRequest)
+		So(serve("POST", "/service/admin/faults?kind=check&duration=1m").Code, ShouldEqual, http.StatusBadRequest)
+		So(serve("POST", "/service/admin/faults?kind=gtg").Code, ShouldEqual, http.StatusBadRequest)
+		So(serve("POST", "/service/admin/faults?kind=latency&duration=1m").Code, ShouldEqual, http.StatusBadRequest)
+	})
+
+	Convey("A GTG fault fails GTG and readyz", t, func() {
+		So(serve("POST", "/service/admin/faults?kind=gtg&duration=1m").Code, ShouldEqual, http.StatusOK)
+		res := serve("GET", "/service/healthcheck/gtg")
+		So(res.Code, ShouldEqual, http.StatusServiceUnavailable)
+		So(res.Body.String(), ShouldEqual, "fault injected")
+		res = serve("GET", "/readyz")
+		So(res.Code, ShouldEqual, http.StatusServiceUnavailable)
+		So(res.Body.String(), ShouldContainSubstring, "[-]fault-injection failed: fault injected\n")
+	})
+
+	Convey("An ASG fault fails the canary and livez", t, func() {
+		So(se.InjectFault(Fault{Kind: FaultCanary}, time.Minute), ShouldBeNil)
+		So(serve("GET", "/service/healthcheck/asg").Code, ShouldEqual, http.StatusServiceUnavailable)
+		res := serve("GET", "/livez")
+		So(res.Code, ShouldEqual, http.StatusServiceUnavailable)
+		So(res.Body.String(), ShouldEqual, "[-]fault-injection failed: fault injected\n[+]canary ok\nlivez check failed\n")
+	})
+
+	se.RunHealthChecks()
+
+	Convey("A check fault fails only the named check, without waiting for a run", t, func() {
+		So(serve("POST", "/service/admin/faults?kind=check&name=db&duration=1m").Code, ShouldEqual, http.StatusOK)
+		res := serve("GET", "/service/healthcheck")
+		So(res.Body.String(), ShouldContainSubstring, `"test_name":"db","test_result":"failed"`)
+		So(res.Body.String(), ShouldContainSubstring, `"test_name":"cache","test_result":"passed"`)
+	})
+
+	Convey("Active faults are shown in the status", t, func() {
+		res := serve("GET", "/service/status")
+		So(res.Body.String(), ShouldContainSubstring, `"active_faults":[{"kind":"asg"`)
+		So(res.Body.String(), ShouldContainSubstring, `"kind":"check","name":"db"`)
+		So(res.Body.String(), ShouldContainSubstring, `"kind":"gtg"`)
+		So(res.Body.String(), ShouldContainSubstring, `"set_by":"alice"`)
+	})
+
+	Convey("Faults can be cleared", t, func() {
+		So(len(serve("DELETE", "/service/admin/faults?kind=gtg").Body.String()), ShouldBeGreaterThan, 0)
+		So(serve("GET", "/service/healthcheck/gtg").Code, ShouldEqual, http.StatusOK)
+		So(len(se.Faults()), ShouldEqual, 2)
+		So(serve("DELETE", "/service/admin/faults").Code, ShouldEqual, http.StatusOK)
+		So(se.Faults(), ShouldBeEmpty)
+		So(serve("GET", "/service/status").Body.String(), ShouldNotContainSubstring, "active_faults")
+		So(se.AdminActions()[0].Action, ShouldEqual, "inject fault")
+	})
+
+	Convey("Latency is added to the /service handlers", t, func() {
+		So(serve("POST", "/service/admin/faults?kind=latency&latency=50ms&duration=1m").Code, ShouldEqual, http.StatusOK)
+		start := time.Now()
+		So(serve("GET", "/service/healthcheck/gtg").Code, ShouldEqual, http.StatusOK)
+		So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 50*time.Millisecond)
+
+		// not the admin API, so the fault can be cleared quickly
+		start = time.Now()
+		serve("DELETE", "/service/admin/faults")
+		So(time.Since(start), ShouldBeLessThan, 50*time.Millisecond)
+	})
+
+	Convey("Faults expire", t, func() {
+		So(se.InjectFault(Fault{Kind: FaultGoodToGo}, 20*time.Millisecond), ShouldBeNil)
+		So(serve("GET", "/service/healthcheck/gtg").Code, ShouldEqual, http.StatusServiceUnavailable)
+		time.Sleep(30 * time.Millisecond)
+		So(serve("GET", "/service/healthcheck/gtg").Code, ShouldEqual, http.StatusOK)
+		So(se.Faults(), ShouldBeEmpty)
+	})
+
+	Convey("Check faults expire without a run, even with the scheduler paused", t, func() {
+		se.PauseHealthChecks()
+		defer se.ResumeHealthChecks()
+		So(se.InjectFault(Fault{Kind: FaultCheck, Name: "db"}, 20*time.Millisecond), ShouldBeNil)
+		So(serve("GET", "/service/healthcheck").Body.String(), ShouldContainSubstring, `"test_name":"db","test_result":"failed"`)
+		time.Sleep(30 * time.Millisecond)
+		So(serve("GET", "/service/healthcheck").Body.String(), ShouldContainSubstring, `"test_name":"db","test_result":"passed"`)
+	})
+}
@@ -0,0 +1,134 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	routing "github.com/go-ozzo/ozzo-routing"
+)
+
+//
+// Kubernetes style probes, in the format of the kubernetes API server
+// https://kubernetes.io/docs/reference/using-api/health-checks/
+//
+// Name	         HTTP Verb	URI Path	Maps to
+// Liveness	     GET	/livez	Service Canary
+// Readiness	     GET	/readyz	GTG
+// Startup	         GET	/startupz	warm-up tasks
+//
+// ?verbose lists each named check, ?exclude=name skips a check and may be repeated.
+
+// a single named check making up a kubernetes probe
+type namedCheck struct {
+	name   string
+	ok     bool
+	reason string
+}
+
+// Register /livez, /readyz and /startupz on the group, e.g. &router.RouteGroup for the root.
+func (s *StandardEndpoints) RegisterKubernetesEndpoints(group *routing.RouteGroup) {
+	group.Get("/livez", func(c *routing.Context) error {
+		return s.serveKubernetesProbe(c, "livez", s.livenessChecks)
+	})
+	group.Get("/readyz", func(c *routing.Context) error {
+		return s.serveKubernetesProbe(c, "readyz", s.readinessChecks)
+	})
+	group.Get("/startupz", func(c *routing.Context) error {
+		return s.serveKubernetesProbe(c, "startupz", s.startupChecks)
+	})
+}
+
+// the same conditions as ASG, but each is evaluated and reported separately
+func (s *StandardEndpoints) livenessChecks(exclude map[string]bool) []namedCheck {
+	return evaluateConditions(s.serviceCanaryConditions(), exclude)
+}
+
+// the same conditions as GTG, but each is evaluated and reported separately
+func (s *StandardEndpoints) readinessChecks(exclude map[string]bool) []namedCheck {
+	return evaluateConditions(s.goodToGoConditions(), exclude)
+}
+
+// evaluate each condition that isn't excluded, dropping the name from the start of the reason
+// as it is already listed, e.g. "maintenance: upgrade" is reported as "[-]maintenance failed: upgrade"
+func evaluateConditions(conditions []probeCondition, exclude map[string]bool) []namedCheck {
+	var checks []namedCheck
+	for _, c := range conditions {
+		if !exclude[c.name] {
+			checks = append(checks, namedCheck{name: c.name, ok: c.ok(), reason: strings.TrimPrefix(c.reason, c.name+": ")})
+		}
+	}
+	return checks
+}
+
+func (s *StandardEndpoints) startupChecks(exclude map[string]bool) []namedCheck {
+	var checks []namedCheck
+	for _, task := range s.StartupReport().Tasks {
+		if !exclude[task.Name] {
+			checks = append(checks, namedCheck{name: task.Name, ok: task.State == HealthResultPassed, reason: task.Error})
+		}
+	}
+	return checks
+}
+
+// writes "ok" when every check passes, otherwise a 503 with each check listed, which ?verbose always does
+func (s *StandardEndpoints) serveKubernetesProbe(c *routing.Context, probe string, checks func(exclude map[string]bool) []namedCheck) error {
+	query := c.Request.URL.Query()
+	_, verbose := query["verbose"]
+	exclude := map[string]bool{}
+	for _, name := range query["exclude"] {
+		exclude[name] = true
+	}
+
+	var body bytes.Buffer
+	passed := true
+	for _, check := range checks(exclude) {
+		if check.ok {
+			fmt.Fprintf(&body, "[+]%s ok\n", check.name)
+			continue
+		}
+		passed = false
+		if check.reason != "" {
+			fmt.Fprintf(&body, "[-]%s failed: %s\n", check.name, check.reason)
+		} else {
+			fmt.Fprintf(&body, "[-]%s failed\n", check.name)
+		}
+	}
+	for _, name := range query["exclude"] {
+		fmt.Fprintf(&body, "[+]%s excluded: ok\n", name)
+	}
+
+	textDataWriter := &TextPlainDataWriter{}
+	textDataWriter.SetHeader(c.Response)
+	c.SetDataWriter(textDataWriter)
+	switch {
+	case passed && !verbose:
+		c.Response.WriteHeader(http.StatusOK)
+		return c.Write("ok")
+	case passed:
+		c.Response.WriteHeader(http.StatusOK)
+		return c.Write(body.String() + probe + " check passed\n")
+	default:
+		c.Response.WriteHeader(http.StatusServiceUnavailable)
+		return c.Write(body.String() + probe + " check failed\n")
+	}
+}
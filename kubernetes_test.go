@@ -0,0 +1,93 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-ozzo/ozzo-routing"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestKubernetesEndpoints(t *testing.T) {
+	r := routing.New()
+	se := NewStandardEndpoints()
+	se.SetLogger(nil)
+	se.RegisterDefaultEndpoints(r)
+	se.RegisterKubernetesEndpoints(&r.RouteGroup)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(res, req)
+		return res
+	}
+
+	Convey("All probes pass by default", t, func() {
+		for _, path := range []string{"/livez", "/readyz", "/startupz"} {
+			res := serve(path)
+			So(res.Code, ShouldEqual, http.StatusOK)
+			So(contentType(res), ShouldStartWith, "text/plain")
+			So(res.Body.String(), ShouldEqual, "ok")
+		}
+	})
+
+	Convey("Verbose lists each check", t, func() {
+		res := serve("/readyz?verbose")
+		So(res.Code, ShouldEqual, http.StatusOK)
+		So(res.Body.String(), ShouldEqual, "[+]draining ok\n[+]maintenance ok\n[+]warm-up ok\nreadyz check passed\n")
+	})
+
+	se.SetServiceCanaryFunc(func() bool { return false })
+	se.SetGoodToGoFunc(func() bool { return false })
+	se.StartMaintenance("upgrade", time.Time{})
+	se.AddWarmUpTask("cache", func(ctx context.Context) error { return errors.New("timeout") })
+	se.RunWarmUp(context.Background())
+
+	Convey("Failures are listed with a 503", t, func() {
+		res := serve("/livez")
+		So(res.Code, ShouldEqual, http.StatusServiceUnavailable)
+		So(res.Body.String(), ShouldEqual, "[-]canary failed\nlivez check failed\n")
+
+		res = serve("/readyz")
+		So(res.Code, ShouldEqual, http.StatusServiceUnavailable)
+		So(res.Body.String(), ShouldContainSubstring, "[-]maintenance failed: upgrade\n")
+		So(res.Body.String(), ShouldContainSubstring, "[-]warm-up failed: warming up\n")
+		So(res.Body.String(), ShouldContainSubstring, "[-]gtg failed\n")
+
+		res = serve("/startupz")
+		So(res.Code, ShouldEqual, http.StatusServiceUnavailable)
+		So(res.Body.String(), ShouldEqual, "[-]cache failed: timeout\nstartupz check failed\n")
+	})
+
+	Convey("Excluded checks are skipped", t, func() {
+		res := serve("/readyz?exclude=maintenance&exclude=warm-up&exclude=gtg")
+		So(res.Code, ShouldEqual, http.StatusOK)
+		So(res.Body.String(), ShouldEqual, "ok")
+
+		res = serve("/livez?exclude=canary&verbose")
+		So(res.Code, ShouldEqual, http.StatusOK)
+		So(res.Body.String(), ShouldEqual, "[+]canary excluded: ok\nlivez check passed\n")
+	})
+}
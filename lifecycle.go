@@ -0,0 +1,124 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrClosed  = errors.New("se4: standard endpoints are closed")
+	ErrStopped = errors.New("se4: health checks are stopped")
+)
+
+// Start running the health checks, right away then at the interval given to SetHealthCheckFuncs,
+// or DefaultHealthCheckInterval if that isn't positive.
+// They run until the context is done or Stop is called, starting while already running does nothing.
+func (s *StandardEndpoints) Start(ctx context.Context) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	s.stopped = false
+	if s.manualRuns.Err() != nil {
+		s.manualRuns, s.cancelManualRuns = context.WithCancel(context.Background())
+	}
+	if s.schedulerDone != nil {
+		select {
+		case <-s.schedulerDone:
+		default:
+			return nil // already running
+		}
+	}
+
+	s.schedulerParent = ctx
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	s.stopScheduler, s.schedulerDone = cancel, done
+	go s.scheduleHealthChecks(ctx, done)
+	return nil
+}
+
+// Stop running the health checks, waiting for a run that is in progress to finish, whether scheduled or
+// from RunHealthChecks. No further runs happen once it returns, including from RunHealthChecks, until Start is called.
+// It is safe to call more than once, but not from a health check or listener.
+func (s *StandardEndpoints) Stop() {
+	s.locker.Lock()
+	s.stopped = true
+	cancel, done := s.stopScheduler, s.schedulerDone
+	s.cancelManualRuns() // so a remediation in progress gives up
+	s.locker.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	// wait for a manual run, any later one sees stopped and returns
+	s.runLocker.Lock()
+	s.runLocker.Unlock()
+}
+
+// Close stops the health checks for good, they can't be started again, closes the event export
+// and marks the shutdown as clean in the state file.
+func (s *StandardEndpoints) Close() error {
+	s.locker.Lock()
+	s.closed = true
+	s.locker.Unlock()
+	s.Stop()
+	s.events.exportTo(nil)
+
+	// hold the run lock so a manual run can't mark the shutdown unclean again
+	s.runLocker.Lock()
+	defer s.runLocker.Unlock()
+	return s.writeState(true)
+}
+
+func (s *StandardEndpoints) scheduleHealthChecks(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	// fire right away, then adjust to interval
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		s.locker.Lock()
+		paused := s.schedulerPaused
+		s.locker.Unlock()
+		if !paused {
+			s.runHealthChecks(ctx)
+		}
+
+		s.locker.Lock()
+		interval := s.healthCheckInterval
+		s.locker.Unlock()
+		if interval <= 0 {
+			interval = DefaultHealthCheckInterval
+		}
+		timer.Reset(interval)
+	}
+}
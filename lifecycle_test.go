@@ -0,0 +1,169 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLifecycle(t *testing.T) {
+	se := NewStandardEndpoints()
+	se.SetLogger(nil)
+
+	var calls, running int32
+	se.SetHealthCheckFuncs(time.Millisecond, func() HealthCheckResult {
+		atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(time.Millisecond)
+		return HealthCheckResult{Name: "slow", Result: HealthResultPassed}
+	})
+	for atomic.LoadInt32(&calls) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	Convey("Stop waits for the in-flight run and no more runs happen", t, func() {
+		se.Stop()
+		So(atomic.LoadInt32(&running), ShouldEqual, 0)
+		stopped := atomic.LoadInt32(&calls)
+		time.Sleep(5 * time.Millisecond)
+		So(atomic.LoadInt32(&calls), ShouldEqual, stopped)
+		se.Stop() // safe to repeat
+		So(se.RunHealthChecks(), ShouldEqual, ErrStopped)
+		So(atomic.LoadInt32(&calls), ShouldEqual, stopped)
+	})
+
+	Convey("Start resumes the health checks", t, func() {
+		before := atomic.LoadInt32(&calls)
+		So(se.Start(context.Background()), ShouldBeNil)
+		So(se.Start(context.Background()), ShouldBeNil) // already running
+		for i := 0; i < 100 && atomic.LoadInt32(&calls) == before; i++ {
+			time.Sleep(time.Millisecond)
+		}
+		So(atomic.LoadInt32(&calls), ShouldBeGreaterThan, before)
+		So(se.RunHealthChecks(), ShouldBeNil)
+	})
+
+	Convey("Cancelling the context stops the health checks and they can be started again", t, func() {
+		se.Stop()
+		ctx, cancel := context.WithCancel(context.Background())
+		So(se.Start(ctx), ShouldBeNil)
+		cancel()
+		se.Stop()
+		stopped := atomic.LoadInt32(&calls)
+		time.Sleep(5 * time.Millisecond)
+		So(atomic.LoadInt32(&calls), ShouldEqual, stopped)
+		So(se.Start(context.Background()), ShouldBeNil)
+	})
+
+	Convey("Close stops for good", t, func() {
+		So(se.Close(), ShouldBeNil)
+		So(se.Close(), ShouldBeNil)
+		stopped := atomic.LoadInt32(&calls)
+		So(se.Start(context.Background()), ShouldEqual, ErrClosed)
+		So(se.RunHealthChecks(), ShouldEqual, ErrClosed)
+		se.SetHealthCheckFuncs(time.Millisecond, func() HealthCheckResult { return HealthCheckResult{} })
+		time.Sleep(5 * time.Millisecond)
+		So(atomic.LoadInt32(&calls), ShouldEqual, stopped)
+	})
+}
+
+func TestStartWithoutInterval(t *testing.T) {
+	se := NewStandardEndpoints()
+	se.SetLogger(nil)
+	defer se.Close()
+
+	var calls int32
+	se.healthChecks = []HealthCheckFunc{func() HealthCheckResult {
+		atomic.AddInt32(&calls, 1)
+		return HealthCheckResult{Name: "db", Result: HealthResultPassed}
+	}}
+
+	Convey("Start without an interval runs once then waits for the default interval", t, func() {
+		So(se.Start(context.Background()), ShouldBeNil)
+		time.Sleep(20 * time.Millisecond)
+		So(atomic.LoadInt32(&calls), ShouldEqual, 1)
+	})
+}
+
+func TestStopWaitsForManualRun(t *testing.T) {
+	se := NewStandardEndpoints()
+	se.SetLogger(nil)
+	defer se.Close()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	var finished int32
+	se.healthChecks = []HealthCheckFunc{func() HealthCheckResult {
+		close(started)
+		<-release
+		atomic.StoreInt32(&finished, 1)
+		return HealthCheckResult{Name: "db", Result: HealthResultPassed}
+	}}
+	go se.RunHealthChecks()
+	<-started
+
+	Convey("Stop returns once the manual run has finished", t, func() {
+		stopped := make(chan struct{})
+		go func() {
+			se.Stop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+			t.Fatal("Stop returned during the run")
+		case <-time.After(10 * time.Millisecond):
+		}
+		close(release)
+		<-stopped
+		So(atomic.LoadInt32(&finished), ShouldEqual, 1)
+		So(se.RunHealthChecks(), ShouldEqual, ErrStopped)
+	})
+}
+
+func TestSetHealthCheckFuncsKeepsStartContext(t *testing.T) {
+	se := NewStandardEndpoints()
+	se.SetLogger(nil)
+	defer se.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := se.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var calls int32
+	se.SetHealthCheckFuncs(time.Millisecond, func() HealthCheckResult {
+		atomic.AddInt32(&calls, 1)
+		return HealthCheckResult{Name: "db", Result: HealthResultPassed}
+	})
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	Convey("Cancelling the context given to Start stops the replaced checks", t, func() {
+		cancel()
+		time.Sleep(5 * time.Millisecond) // no Stop, the scheduler exits on its own
+		stopped := atomic.LoadInt32(&calls)
+		time.Sleep(5 * time.Millisecond)
+		So(atomic.LoadInt32(&calls), ShouldEqual, stopped)
+	})
+}
@@ -0,0 +1,189 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// HealthTransitionKind
+// "check", "overall", "gtg", "asg"
+const (
+	HealthTransitionCheck    = "check"   // a single health check changed state
+	HealthTransitionOverall  = "overall" // the aggregate of all health checks changed state
+	HealthTransitionGoodToGo = "gtg"     // the good to go probe changed state, seen when the probe is called
+	HealthTransitionCanary   = "asg"     // the service canary probe changed state, seen when the probe is called
+)
+
+// HealthTransition describes a change from one health state to another.
+type HealthTransition struct {
+	Kind      string             `json:"kind"`             // "check", "overall", "gtg" or "asg"
+	Name      string             `json:"name"`             // the check name, otherwise the same as kind
+	Previous  string             `json:"previous"`         // the state before the change, "not_run" if never seen
+	Current   string             `json:"current"`          // the state after the change
+	Result    *HealthCheckResult `json:"result,omitempty"` // the result that caused the change, nil for overall transitions
+	Timestamp time.Time          `json:"timestamp"`        // when the change was detected
+}
+
+// HealthListenerFunc is called for every health state transition.
+type HealthListenerFunc func(HealthTransition)
+
+type healthListener struct {
+	id int
+	fn HealthListenerFunc
+}
+
+// Subscribe to health state transitions, the returned func removes the listener.
+// Listeners are called from the health check goroutine so should not block.
+func (s *StandardEndpoints) SubscribeHealthTransitions(listener HealthListenerFunc) (unsubscribe func()) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	id := s.nextListenerID
+	s.nextListenerID++
+	s.healthListeners = append(s.healthListeners, healthListener{id: id, fn: listener})
+	return func() {
+		s.locker.Lock()
+		defer s.locker.Unlock()
+		for i, l := range s.healthListeners {
+			if l.id == id {
+				s.healthListeners = append(s.healthListeners[:i:i], s.healthListeners[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Set the logger that health state transitions are written to, nil disables logging.
+// Defaults to slog.Default().
+func (s *StandardEndpoints) SetLogger(logger *slog.Logger) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.logger = logger
+}
+
+// OverallHealthState reduces a set of results down to a single state:
+// failed if any check failed, passed if any check passed, otherwise not_run.
+func OverallHealthState(results []HealthCheckResult) string {
+	state := HealthResultNotRun
+	for _, r := range results {
+		switch r.Result {
+		case HealthResultFailed:
+			return HealthResultFailed
+		case HealthResultPassed:
+			state = HealthResultPassed
+		}
+	}
+	return state
+}
+
+// compare the report with the last known states, must be called with the lock held
+func (s *StandardEndpoints) healthTransitions(report HealthCheckReport, at time.Time) []HealthTransition {
+	var transitions []HealthTransition
+	for i := range report.Results {
+		result := report.Results[i]
+		previous, ok := s.checkStates[result.Name]
+		if !ok {
+			previous = HealthResultNotRun
+		}
+		s.checkStates[result.Name] = result.Result
+		if previous != result.Result {
+			transitions = append(transitions, HealthTransition{Kind: HealthTransitionCheck, Name: result.Name,
+				Previous: previous, Current: result.Result, Result: &result, Timestamp: at})
+		}
+	}
+
+	overall := OverallHealthState(report.Results)
+	if overall != s.overallState {
+		transitions = append(transitions, HealthTransition{Kind: HealthTransitionOverall, Name: HealthTransitionOverall,
+			Previous: s.overallState, Current: overall, Timestamp: at})
+		s.overallState = overall
+	}
+	return transitions
+}
+
+// record the outcome of a gtg or asg probe, notifying if it differs from the last probe
+func (s *StandardEndpoints) probeTransition(kind string, ok bool) {
+	state := HealthResultFailed
+	if ok {
+		state = HealthResultPassed
+	}
+	s.locker.Lock()
+	previous, seen := s.probeStates[kind]
+	if !seen {
+		previous = HealthResultNotRun
+	}
+	s.probeStates[kind] = state
+	s.locker.Unlock()
+
+	if previous != state {
+		s.notifyHealthTransitions([]HealthTransition{{Kind: kind, Name: kind,
+			Previous: previous, Current: state, Timestamp: time.Now().UTC()}})
+	}
+}
+
+// call the listeners & log the transitions, must be called without the lock held
+func (s *StandardEndpoints) notifyHealthTransitions(transitions []HealthTransition) {
+	if len(transitions) == 0 {
+		return
+	}
+	s.locker.Lock()
+	logger := s.logger
+	listeners := make([]HealthListenerFunc, 0, len(s.healthListeners))
+	for _, l := range s.healthListeners {
+		listeners = append(listeners, l.fn)
+	}
+	s.locker.Unlock()
+
+	for _, t := range transitions {
+		s.events.record(EventHealthTransition, transitionMessage(t), t)
+		if logger != nil {
+			logTransition(logger, t)
+		}
+		for _, l := range listeners {
+			l(t)
+		}
+	}
+}
+
+// e.g. "check db passed -> failed" or "gtg passed -> failed"
+func transitionMessage(t HealthTransition) string {
+	if t.Name == t.Kind {
+		return t.Kind + " " + t.Previous + " -> " + t.Current
+	}
+	return t.Kind + " " + t.Name + " " + t.Previous + " -> " + t.Current
+}
+
+func logTransition(logger *slog.Logger, t HealthTransition) {
+	level := slog.LevelInfo
+	if t.Current == HealthResultFailed {
+		level = slog.LevelWarn
+	}
+	attrs := []slog.Attr{
+		slog.String("kind", t.Kind),
+		slog.String("name", t.Name),
+		slog.String("previous", t.Previous),
+		slog.String("current", t.Current),
+	}
+	if t.Result != nil {
+		attrs = append(attrs, slog.Float64("duration_millis", t.Result.DurationMillis))
+	}
+	logger.LogAttrs(context.Background(), level, "health state changed", attrs...)
+}
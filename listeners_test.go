@@ -0,0 +1,96 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"bytes"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHealthTransitions(t *testing.T) {
+	se := NewStandardEndpoints()
+	var logBuf bytes.Buffer
+	se.SetLogger(slog.New(slog.NewTextHandler(&logBuf, nil)))
+
+	var mu sync.Mutex
+	var transitions []HealthTransition
+	unsubscribe := se.SubscribeHealthTransitions(func(t HealthTransition) {
+		mu.Lock()
+		defer mu.Unlock()
+		transitions = append(transitions, t)
+	})
+
+	state := HealthResultPassed
+	ran := make(chan struct{}, 100)
+	se.SetHealthCheckFuncs(time.Millisecond, func() HealthCheckResult {
+		mu.Lock()
+		defer mu.Unlock()
+		defer func() { ran <- struct{}{} }()
+		return HealthCheckResult{Name: "db", Result: state, Timestamp: time.Now()}
+	})
+
+	<-ran
+	<-ran
+	mu.Lock()
+	state = HealthResultFailed
+	mu.Unlock()
+	<-ran
+	<-ran
+	unsubscribe()
+	se.SetHealthCheckFuncs(0)
+	time.Sleep(2 * time.Millisecond)
+
+	Convey("Check and overall transitions are delivered once per change", t, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		So(len(transitions), ShouldEqual, 4)
+
+		So(transitions[0].Kind, ShouldEqual, HealthTransitionCheck)
+		So(transitions[0].Name, ShouldEqual, "db")
+		So(transitions[0].Previous, ShouldEqual, HealthResultNotRun)
+		So(transitions[0].Current, ShouldEqual, HealthResultPassed)
+		So(transitions[0].Result, ShouldNotBeNil)
+
+		So(transitions[1].Kind, ShouldEqual, HealthTransitionOverall)
+		So(transitions[1].Current, ShouldEqual, HealthResultPassed)
+		So(transitions[1].Result, ShouldBeNil)
+
+		So(transitions[2].Previous, ShouldEqual, HealthResultPassed)
+		So(transitions[2].Current, ShouldEqual, HealthResultFailed)
+		So(transitions[3].Kind, ShouldEqual, HealthTransitionOverall)
+		So(transitions[3].Current, ShouldEqual, HealthResultFailed)
+	})
+
+	Convey("Transitions are logged", t, func() {
+		So(logBuf.String(), ShouldContainSubstring, "health state changed")
+		So(logBuf.String(), ShouldContainSubstring, "level=WARN")
+		So(logBuf.String(), ShouldContainSubstring, "name=db")
+	})
+
+	Convey("Overall health state", t, func() {
+		So(OverallHealthState(nil), ShouldEqual, HealthResultNotRun)
+		So(OverallHealthState([]HealthCheckResult{{Result: HealthResultPassed}, {Result: HealthResultNotRun}}), ShouldEqual, HealthResultPassed)
+		So(OverallHealthState([]HealthCheckResult{{Result: HealthResultPassed}, {Result: HealthResultFailed}}), ShouldEqual, HealthResultFailed)
+	})
+}
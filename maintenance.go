@@ -0,0 +1,106 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	routing "github.com/go-ozzo/ozzo-routing"
+)
+
+var (
+	DefaultMaintenanceRetryAfter = time.Duration(60) * time.Second // used when there is no expected end time
+)
+
+// Maintenance describes why an instance has been taken out of rotation.
+type Maintenance struct {
+	Reason      string     `json:"reason"`
+	Since       time.Time  `json:"since"`
+	ExpectedEnd *time.Time `json:"expected_end,omitempty"`
+}
+
+// Put the instance into maintenance, GTG fails with the reason until EndMaintenance is called.
+// The expected end is informational, a zero time means unknown.
+func (s *StandardEndpoints) StartMaintenance(reason string, expectedEnd time.Time) {
+	m := &Maintenance{Reason: reason, Since: time.Now().UTC()}
+	if !expectedEnd.IsZero() {
+		end := expectedEnd.UTC()
+		m.ExpectedEnd = &end
+	}
+	s.locker.Lock()
+	s.Status.Maintenance = m
+	s.locker.Unlock()
+	s.log("maintenance started", "reason", reason)
+	s.events.record(EventMaintenance, "maintenance started", m)
+}
+
+func (s *StandardEndpoints) EndMaintenance() {
+	s.locker.Lock()
+	active := s.Status.Maintenance != nil
+	s.Status.Maintenance = nil
+	s.locker.Unlock()
+	if active {
+		s.log("maintenance ended")
+		s.events.record(EventMaintenance, "maintenance ended", nil)
+	}
+}
+
+// Maintenance returns the active maintenance, or nil.
+func (s *StandardEndpoints) Maintenance() *Maintenance {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return s.Status.Maintenance
+}
+
+// MaintenanceMiddleware rejects requests with a 503 and a Retry-After header while maintenance is active.
+// Paths under any of the exempt prefixes are let through, by default "/service", which exempts
+// "/service" and "/service/..." but not "/services/...".
+func (s *StandardEndpoints) MaintenanceMiddleware(exemptPrefixes ...string) routing.Handler {
+	if len(exemptPrefixes) == 0 {
+		exemptPrefixes = []string{"/service"}
+	}
+	return func(c *routing.Context) error {
+		m := s.Maintenance()
+		if m == nil {
+			return nil
+		}
+		path := c.Request.URL.Path
+		for _, prefix := range exemptPrefixes {
+			prefix = strings.TrimSuffix(prefix, "/")
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				return nil
+			}
+		}
+		c.Response.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(m, time.Now())))
+		c.Abort()
+		return routing.NewHTTPError(http.StatusServiceUnavailable, m.Reason)
+	}
+}
+
+func retryAfterSeconds(m *Maintenance, now time.Time) int {
+	wait := DefaultMaintenanceRetryAfter
+	if m.ExpectedEnd != nil && m.ExpectedEnd.After(now) {
+		wait = m.ExpectedEnd.Sub(now)
+	}
+	return int(math.Ceil(wait.Seconds()))
+}
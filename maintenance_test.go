@@ -0,0 +1,91 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-ozzo/ozzo-routing"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMaintenance(t *testing.T) {
+	r := routing.New()
+	se := NewStandardEndpoints()
+	se.SetLogger(nil)
+	r.Use(se.MaintenanceMiddleware())
+	se.RegisterDefaultEndpoints(r)
+	r.Get("/api/thing", func(c *routing.Context) error {
+		return c.Write("thing")
+	})
+	r.Get("/services/orders", func(c *routing.Context) error {
+		return c.Write("orders")
+	})
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(res, req)
+		return res
+	}
+
+	Convey("Requests pass when not in maintenance", t, func() {
+		So(serve("/api/thing").Code, ShouldEqual, http.StatusOK)
+		So(serve("/service/healthcheck/gtg").Code, ShouldEqual, http.StatusOK)
+		So(se.Maintenance(), ShouldBeNil)
+	})
+
+	se.StartMaintenance("database upgrade", time.Now().Add(90*time.Second))
+
+	Convey("GTG fails with the reason during maintenance", t, func() {
+		res := serve("/service/healthcheck/gtg")
+		So(res.Code, ShouldEqual, http.StatusServiceUnavailable)
+		So(res.Body.String(), ShouldEqual, "maintenance: database upgrade")
+	})
+
+	Convey("Status shows the maintenance", t, func() {
+		res := serve("/service/status")
+		So(res.Code, ShouldEqual, http.StatusOK)
+		So(res.Body.String(), ShouldContainSubstring, `"maintenance":{"reason":"database upgrade"`)
+		So(res.Body.String(), ShouldContainSubstring, `"expected_end"`)
+	})
+
+	Convey("Other requests are rejected with Retry-After", t, func() {
+		res := serve("/api/thing")
+		So(res.Code, ShouldEqual, http.StatusServiceUnavailable)
+		So(res.Header().Get("Retry-After"), ShouldBeIn, []string{"89", "90"})
+		So(res.Body.String(), ShouldContainSubstring, "database upgrade")
+		So(serve("/services/orders").Code, ShouldEqual, http.StatusServiceUnavailable)
+	})
+
+	se.EndMaintenance()
+
+	Convey("Requests pass after maintenance ends", t, func() {
+		So(serve("/api/thing").Code, ShouldEqual, http.StatusOK)
+		So(serve("/service/healthcheck/gtg").Code, ShouldEqual, http.StatusOK)
+		So(serve("/service/status").Body.String(), ShouldNotContainSubstring, "maintenance")
+	})
+
+	Convey("Retry-After defaults without an expected end", t, func() {
+		So(retryAfterSeconds(&Maintenance{}, time.Now()), ShouldEqual, 60)
+	})
+}
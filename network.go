@@ -0,0 +1,114 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"net"
+)
+
+var (
+	// dialed over UDP to find the outbound address, which sends no packets
+	DefaultOutboundProbeAddress = "8.8.8.8:53"
+)
+
+// NetworkInfo is how the instance can be reached.
+type NetworkInfo struct {
+	Interfaces        []InterfaceAddresses `json:"interfaces"`
+	ListenAddresses   []string             `json:"listen_addresses"`
+	OutboundAddress   string               `json:"outbound_address,omitempty"`   // the local IP of the default route
+	AdvertisedAddress string               `json:"advertised_address,omitempty"` // set by SetAdvertisedAddress, or the outbound address & listen port
+}
+
+// InterfaceAddresses are the non-loopback addresses of an interface that is up.
+type InterfaceAddresses struct {
+	Name      string   `json:"name"`
+	Addresses []string `json:"addresses"`
+}
+
+// Record an address the service listens on, GracefulServer records its own.
+func (s *StandardEndpoints) AddListenAddress(addr string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	for _, existing := range s.listenAddresses {
+		if existing == addr {
+			return
+		}
+	}
+	s.listenAddresses = append(s.listenAddresses, addr)
+}
+
+// Set the address other services should use to reach this one, e.g. behind NAT.
+func (s *StandardEndpoints) SetAdvertisedAddress(addr string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.advertisedAddress = addr
+}
+
+// combine the interfaces & outbound address, read without the lock, with the addresses set on the endpoints.
+// must be called with the lock held
+func (s *StandardEndpoints) networkInfo(interfaces []InterfaceAddresses, outbound string) *NetworkInfo {
+	info := &NetworkInfo{Interfaces: interfaces, ListenAddresses: append([]string{}, s.listenAddresses...),
+		OutboundAddress: outbound, AdvertisedAddress: s.advertisedAddress}
+	if info.AdvertisedAddress == "" && info.OutboundAddress != "" && len(info.ListenAddresses) > 0 {
+		if _, port, err := net.SplitHostPort(info.ListenAddresses[0]); err == nil {
+			info.AdvertisedAddress = net.JoinHostPort(info.OutboundAddress, port)
+		}
+	}
+	return info
+}
+
+func interfaceAddresses() []InterfaceAddresses {
+	result := []InterfaceAddresses{}
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return result
+	}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		entry := InterfaceAddresses{Name: iface.Name, Addresses: []string{}}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() {
+				entry.Addresses = append(entry.Addresses, ipNet.IP.String())
+			}
+		}
+		if len(entry.Addresses) > 0 {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// the local IP the OS picks to reach the probe address, empty without a route
+func outboundAddress(probe string) string {
+	conn, err := net.Dial("udp", probe)
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+		return addr.IP.String()
+	}
+	return ""
+}
@@ -0,0 +1,84 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNetworkInfo(t *testing.T) {
+	Convey("Interface addresses leave out loopback", t, func() {
+		for _, iface := range interfaceAddresses() {
+			So(len(iface.Addresses), ShouldBeGreaterThan, 0)
+			for _, addr := range iface.Addresses {
+				So(net.ParseIP(addr).IsLoopback(), ShouldBeFalse)
+			}
+		}
+	})
+
+	Convey("The outbound address is the local side of the route", t, func() {
+		So(outboundAddress("127.0.0.1:53"), ShouldEqual, "127.0.0.1")
+		So(outboundAddress("not an address"), ShouldEqual, "")
+	})
+
+	Convey("The advertised address defaults to the outbound address and listen port", t, func() {
+		probe := DefaultOutboundProbeAddress
+		DefaultOutboundProbeAddress = "127.0.0.1:53"
+		defer func() { DefaultOutboundProbeAddress = probe }()
+
+		se := NewStandardEndpoints()
+		se.AddListenAddress("[::]:8080")
+		se.AddListenAddress("[::]:8080")
+		network := se.generateStatus().Network
+		So(network.ListenAddresses, ShouldResemble, []string{"[::]:8080"})
+		So(network.OutboundAddress, ShouldEqual, "127.0.0.1")
+		So(network.AdvertisedAddress, ShouldEqual, "127.0.0.1:8080")
+
+		se.SetAdvertisedAddress("checkout.internal:443")
+		So(se.generateStatus().Network.AdvertisedAddress, ShouldEqual, "checkout.internal:443")
+	})
+
+	Convey("The graceful server records the address it listens on", t, func() {
+		g, url, done := startGracefulServer()
+		_, body := get(url + "/service/status")
+		var status struct {
+			Network NetworkInfo `json:"network"`
+		}
+		So(json.Unmarshal([]byte(body), &status), ShouldBeNil)
+		So(status.Network.ListenAddresses, ShouldResemble, []string{strings.TrimPrefix(url, "http://")})
+
+		g.DrainPeriod = 0
+		So(g.Shutdown(context.Background()), ShouldBeNil)
+		So(<-done, ShouldBeNil)
+	})
+
+	Convey("ListenAndServe fails when the address can't be listened on", t, func() {
+		l, _ := net.Listen("tcp", "127.0.0.1:0")
+		defer l.Close()
+		g := NewGracefulServer(&http.Server{Addr: l.Addr().String()}, NewStandardEndpoints())
+		So(g.ListenAndServe(), ShouldNotBeNil)
+	})
+}
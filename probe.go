@@ -0,0 +1,196 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"sync"
+	"time"
+)
+
+// ProbeOptions control how the GoodToGoFunc and ServiceCanaryFunc are evaluated.
+// Concurrent probe requests always share a single evaluation.
+type ProbeOptions struct {
+	CacheTTL time.Duration // reuse the last result for this long, 0 evaluates on every request
+	Timeout  time.Duration // treat an evaluation taking longer than this as failed, 0 waits for it to finish. A timed out evaluation is still shared until it returns
+}
+
+// probe caches and coalesces the evaluation of a probe func
+type probe struct {
+	locker   *sync.Mutex
+	result   bool
+	expires  time.Time
+	inflight chan struct{} // closed when the running evaluation completes
+}
+
+func newProbe() *probe {
+	return &probe{locker: &sync.Mutex{}}
+}
+
+// forget any cached result, e.g. because the func changed
+func (p *probe) reset() {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	p.expires = time.Time{}
+}
+
+func (p *probe) evaluate(fn func() bool, opts ProbeOptions) bool {
+	p.locker.Lock()
+	if opts.CacheTTL > 0 && time.Now().Before(p.expires) {
+		defer p.locker.Unlock()
+		return p.result
+	}
+	// share the running evaluation, it stays in flight until fn returns even when callers time out
+	wait := p.inflight
+	start := wait == nil
+	if start {
+		wait = make(chan struct{})
+		p.inflight = wait
+	}
+	p.locker.Unlock()
+
+	if start && opts.Timeout <= 0 {
+		p.run(fn, opts.CacheTTL, wait)
+	} else if start {
+		go p.run(fn, opts.CacheTTL, wait)
+	}
+
+	var timeout <-chan time.Time
+	if opts.Timeout > 0 {
+		timer := time.NewTimer(opts.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-wait:
+	case <-timeout:
+		return false
+	}
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	return p.result
+}
+
+// run fn and publish the result to the waiters, a panic is a failed result
+func (p *probe) run(fn func() bool, ttl time.Duration, done chan struct{}) {
+	result := false
+	defer func() {
+		p.locker.Lock()
+		defer p.locker.Unlock()
+		p.result = result
+		p.expires = time.Now().Add(ttl)
+		p.inflight = nil
+		close(done)
+	}()
+	result = callSafely(fn)
+}
+
+func callSafely(fn func() bool) (result bool) {
+	defer func() {
+		if recover() != nil {
+			result = false
+		}
+	}()
+	return fn()
+}
+
+// Set how the good to go and service canary funcs are cached and timed out.
+func (s *StandardEndpoints) SetProbeOptions(opts ProbeOptions) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.probeOptions = opts
+	s.gtgProbe.reset()
+	s.canaryProbe.reset()
+}
+
+// a named condition making up the GTG or ASG result, ok is only called when it is evaluated
+// so the probe funcs aren't called needlessly
+type probeCondition struct {
+	name   string
+	reason string // why it isn't ok, when known
+	ok     func() bool
+}
+
+// the conditions making up GTG in the order they are checked, shared by /healthcheck/gtg and /readyz.
+// Never ok while draining, in maintenance or warming up. The fault is only included while one is
+// injected and gtg only when there is a func.
+func (s *StandardEndpoints) goodToGoConditions() []probeCondition {
+	s.locker.Lock()
+	gtgCheck, opts, draining, maintenance := s.gtgCheck, s.probeOptions, s.draining, s.Status.Maintenance
+	warmedUp := s.warmedUp()
+	_, fault := s.activeFault(FaultGoodToGo, "")
+	s.locker.Unlock()
+
+	conditions := []probeCondition{
+		{name: "draining", reason: "draining", ok: func() bool { return !draining }},
+		{name: "maintenance", ok: func() bool { return maintenance == nil }},
+		{name: "warm-up", reason: "warming up", ok: func() bool { return warmedUp }},
+	}
+	if maintenance != nil {
+		conditions[1].reason = "maintenance: " + maintenance.Reason
+	}
+	if fault {
+		conditions = append(conditions, probeCondition{name: "fault-injection", reason: "fault injected", ok: func() bool { return false }})
+	}
+	if gtgCheck != nil {
+		conditions = append(conditions, probeCondition{name: "gtg", ok: func() bool { return s.gtgProbe.evaluate(gtgCheck, opts) }})
+	}
+	return conditions
+}
+
+// the conditions making up ASG, shared by /healthcheck/asg and /livez.
+// The fault is only included while one is injected, canary defaults to ok when there isn't a func.
+func (s *StandardEndpoints) serviceCanaryConditions() []probeCondition {
+	s.locker.Lock()
+	canaryCheck, opts := s.canaryCheck, s.probeOptions
+	_, fault := s.activeFault(FaultCanary, "")
+	s.locker.Unlock()
+
+	var conditions []probeCondition
+	if fault {
+		conditions = append(conditions, probeCondition{name: "fault-injection", reason: "fault injected", ok: func() bool { return false }})
+	}
+	conditions = append(conditions, probeCondition{name: "canary", ok: func() bool {
+		return canaryCheck == nil || s.canaryProbe.evaluate(canaryCheck, opts)
+	}})
+	return conditions
+}
+
+// ok when every condition is, stopping at the first that isn't, the reason explains why when known
+func checkConditions(conditions []probeCondition) (result bool, reason string) {
+	for _, c := range conditions {
+		if !c.ok() {
+			return false, c.reason
+		}
+	}
+	return true, ""
+}
+
+// evaluate the good to go conditions, the reason explains why it isn't ok when known
+func (s *StandardEndpoints) evaluateGoodToGo() (result bool, reason string) {
+	result, reason = checkConditions(s.goodToGoConditions())
+	s.probeTransition(HealthTransitionGoodToGo, result)
+	return result, reason
+}
+
+// evaluate the service canary conditions
+func (s *StandardEndpoints) evaluateServiceCanary() bool {
+	result, _ := checkConditions(s.serviceCanaryConditions())
+	s.probeTransition(HealthTransitionCanary, result)
+	return result
+}
@@ -0,0 +1,162 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-ozzo/ozzo-routing"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestProbeCaching(t *testing.T) {
+	r := routing.New()
+	se := NewStandardEndpoints()
+	se.SetLogger(nil)
+	se.RegisterDefaultEndpoints(r)
+
+	var calls int32
+	se.SetGoodToGoFunc(func() bool {
+		atomic.AddInt32(&calls, 1)
+		return true
+	})
+	se.SetProbeOptions(ProbeOptions{CacheTTL: time.Hour})
+
+	Convey("GTG result is cached for the TTL", t, func() {
+		for i := 0; i < 5; i++ {
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/service/healthcheck/gtg", nil)
+			r.ServeHTTP(res, req)
+			So(res.Code, ShouldEqual, http.StatusOK)
+		}
+		So(atomic.LoadInt32(&calls), ShouldEqual, 1)
+	})
+
+	Convey("Changing the func discards the cached result", t, func() {
+		se.SetGoodToGoFunc(func() bool { return false })
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/service/healthcheck/gtg", nil)
+		r.ServeHTTP(res, req)
+		So(res.Code, ShouldEqual, http.StatusServiceUnavailable)
+	})
+}
+
+func TestProbeTimeout(t *testing.T) {
+	r := routing.New()
+	se := NewStandardEndpoints()
+	se.SetLogger(nil)
+	se.RegisterDefaultEndpoints(r)
+
+	release := make(chan struct{})
+	defer close(release)
+	se.SetServiceCanaryFunc(func() bool {
+		<-release
+		return true
+	})
+	se.SetProbeOptions(ProbeOptions{Timeout: time.Millisecond})
+
+	Convey("Slow canary fails with 503", t, func() {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/service/healthcheck/asg", nil)
+		r.ServeHTTP(res, req)
+		So(res.Code, ShouldEqual, http.StatusServiceUnavailable)
+	})
+}
+
+func TestProbeCoalescing(t *testing.T) {
+	p := newProbe()
+	var calls int32
+	release := make(chan struct{})
+	fn := func() bool {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return true
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan bool, 10)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results <- p.evaluate(fn, ProbeOptions{})
+	}()
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 9; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- p.evaluate(fn, ProbeOptions{})
+		}()
+	}
+	time.Sleep(20 * time.Millisecond) // let the others join the in-flight evaluation
+	close(release)
+	wg.Wait()
+	close(results)
+
+	Convey("Concurrent evaluations share one call", t, func() {
+		So(atomic.LoadInt32(&calls), ShouldEqual, 1)
+		for result := range results {
+			So(result, ShouldBeTrue)
+		}
+	})
+}
+
+func TestProbePanics(t *testing.T) {
+	p := newProbe()
+	panics := func() bool { panic("dependency client blew up") }
+
+	Convey("A panicking func fails and later evaluations still run", t, func() {
+		So(p.evaluate(panics, ProbeOptions{}), ShouldBeFalse)
+		So(p.evaluate(func() bool { return true }, ProbeOptions{}), ShouldBeTrue)
+	})
+
+	Convey("A panic with a timeout fails instead of crashing", t, func() {
+		So(p.evaluate(panics, ProbeOptions{Timeout: time.Second}), ShouldBeFalse)
+		So(p.evaluate(func() bool { return true }, ProbeOptions{Timeout: time.Second}), ShouldBeTrue)
+	})
+}
+
+func TestProbeTimeoutKeepsEvaluationInFlight(t *testing.T) {
+	p := newProbe()
+	var calls int32
+	release := make(chan struct{})
+	hung := func() bool {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return true
+	}
+
+	Convey("Timed out probes wait on the hung evaluation rather than starting more", t, func() {
+		for i := 0; i < 5; i++ {
+			So(p.evaluate(hung, ProbeOptions{Timeout: time.Millisecond}), ShouldBeFalse)
+		}
+		So(atomic.LoadInt32(&calls), ShouldEqual, 1)
+
+		close(release)
+		So(p.evaluate(hung, ProbeOptions{Timeout: time.Second}), ShouldBeTrue)
+		So(atomic.LoadInt32(&calls), ShouldBeLessThanOrEqualTo, 2)
+	})
+}
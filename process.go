@@ -0,0 +1,118 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"net/url"
+	"os"
+	"strings"
+
+	sigar "github.com/cloudfoundry/gosigar"
+)
+
+const redacted = "REDACTED"
+
+var (
+	// command line arguments with a name containing one of these have their value redacted
+	DefaultRedactedArgs = []string{"password", "passwd", "secret", "token", "key", "credential", "auth"}
+)
+
+// ProcessInfo describes this process, it is what to look at first during an incident.
+type ProcessInfo struct {
+	Pid             int      `json:"pid"`
+	Ppid            int      `json:"ppid"`
+	ResidentBytes   uint64   `json:"rss_bytes"`
+	VirtualBytes    uint64   `json:"virtual_bytes"`
+	UserCPUMillis   uint64   `json:"user_cpu_millis"`
+	SystemCPUMillis uint64   `json:"system_cpu_millis"`
+	OpenFDs         int      `json:"open_fds"`
+	MaxFDs          uint64   `json:"max_fds"` // the soft limit
+	Threads         int      `json:"threads"`
+	Cmdline         []string `json:"cmdline"` // with secrets redacted
+}
+
+// fields that can't be read are left as 0
+func readProcessInfo() *ProcessInfo {
+	pid := os.Getpid()
+	info := &ProcessInfo{Pid: pid, Ppid: os.Getppid(), Cmdline: redactArgs(os.Args)}
+
+	mem := sigar.ProcMem{}
+	if err := mem.Get(pid); err == nil {
+		info.ResidentBytes, info.VirtualBytes = mem.Resident, mem.Size
+	}
+	times := sigar.ProcTime{}
+	if err := times.Get(pid); err == nil {
+		info.UserCPUMillis, info.SystemCPUMillis = times.User, times.Sys
+	}
+	state := sigar.ProcState{}
+	if err := state.Get(pid); err == nil && state.Ppid != 0 {
+		info.Ppid = state.Ppid
+	}
+	info.OpenFDs, info.MaxFDs = fdUsage()
+	info.Threads = threadCount()
+	return info
+}
+
+// redact the values of secret looking arguments, in the forms --password=x, --password x & password=x,
+// and the password in URLs
+func redactArgs(args []string) []string {
+	out := make([]string, len(args))
+	redactNext := false
+	for i, arg := range args {
+		switch {
+		case redactNext && !strings.HasPrefix(arg, "-"):
+			out[i] = redacted
+		case strings.Contains(arg, "="):
+			name := arg[:strings.Index(arg, "=")]
+			if isSecretName(name) {
+				out[i] = name + "=" + redacted
+			} else {
+				out[i] = name + "=" + redactURL(arg[len(name)+1:])
+			}
+		default:
+			out[i] = redactURL(arg)
+		}
+		redactNext = strings.HasPrefix(arg, "-") && !strings.Contains(arg, "=") && isSecretName(arg)
+	}
+	return out
+}
+
+func isSecretName(name string) bool {
+	name = strings.ToLower(strings.TrimLeft(name, "-"))
+	for _, secret := range DefaultRedactedArgs {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactURL(arg string) string {
+	if !strings.Contains(arg, "://") {
+		return arg
+	}
+	u, err := url.Parse(arg)
+	if err != nil || u.User == nil {
+		return arg
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	return u.String()
+}
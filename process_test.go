@@ -0,0 +1,55 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestProcessInfo(t *testing.T) {
+	Convey("Secrets are redacted from the command line", t, func() {
+		So(redactArgs([]string{"svc", "--port=8080", "--db-password=hunter2", "--api-token", "abc", "-v",
+			"AWS_SECRET_ACCESS_KEY=xyz", "--db=postgres://app:hunter2@db:5432/app", "--verbose", "--auth"}),
+			ShouldResemble, []string{"svc", "--port=8080", "--db-password=REDACTED", "--api-token", "REDACTED", "-v",
+				"AWS_SECRET_ACCESS_KEY=REDACTED", "--db=postgres://app:REDACTED@db:5432/app", "--verbose", "--auth"})
+	})
+
+	Convey("A secret flag followed by another flag doesn't redact it", t, func() {
+		So(redactArgs([]string{"--use-token", "--port", "80"}), ShouldResemble, []string{"--use-token", "--port", "80"})
+	})
+
+	Convey("The process details are read", t, func() {
+		info := readProcessInfo()
+		So(info.Pid, ShouldEqual, os.Getpid())
+		So(info.Ppid, ShouldEqual, os.Getppid())
+		So(info.ResidentBytes, ShouldBeGreaterThan, 0)
+		So(info.VirtualBytes, ShouldBeGreaterThanOrEqualTo, info.ResidentBytes)
+		So(info.OpenFDs, ShouldBeGreaterThan, 0)
+		So(info.MaxFDs, ShouldBeGreaterThanOrEqualTo, uint64(info.OpenFDs))
+		So(info.Threads, ShouldBeGreaterThan, 0)
+		So(len(info.Cmdline), ShouldEqual, len(os.Args))
+	})
+
+	Convey("The status includes the process", t, func() {
+		So(NewStandardEndpoints().generateStatus().Process, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,192 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const EventRemediation = "remediation"
+
+// RemediationOutcome
+// "waiting", "succeeded", "failed", "cooldown", "exhausted"
+const (
+	RemediationWaiting   = "waiting"   // failing, but not for long enough to remediate
+	RemediationSucceeded = "succeeded" // the last attempt returned no error
+	RemediationFailed    = "failed"    // the last attempt returned an error
+	RemediationCooldown  = "cooldown"  // waiting for the cooldown before the next attempt
+	RemediationExhausted = "exhausted" // no attempts left until the check passes again
+)
+
+var (
+	DefaultRemediationTimeout = 30 * time.Second
+)
+
+// RemediationFunc tries to fix the cause of a failing health check, e.g. reconnecting a pool.
+// It should give up once the context is done, at the policy's Timeout or when the health checks are stopped.
+type RemediationFunc func(ctx context.Context, result HealthCheckResult) error
+
+// RemediationPolicy controls when a remediation runs.
+type RemediationPolicy struct {
+	AfterFailures int           // consecutive failures before the first attempt, at least 1
+	Cooldown      time.Duration // the minimum time between attempts
+	MaxAttempts   int           // attempts allowed until the check passes again, 0 for no limit
+	Timeout       time.Duration // for each attempt, DefaultRemediationTimeout if 0
+}
+
+// RemediationStatus is added to the check's result details as "remediation" while it is failing or being remediated.
+type RemediationStatus struct {
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Attempts            int        `json:"attempts"`
+	Outcome             string     `json:"outcome"` // "waiting", "succeeded", "failed", "cooldown", "exhausted"
+	LastAttempt         *time.Time `json:"last_attempt,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+type remediation struct {
+	policy RemediationPolicy
+	fn     RemediationFunc
+	status RemediationStatus
+}
+
+// Attach a remediation to the health check with the name, it runs after the check has failed
+// policy.AfterFailures times in a row. Attempts start over once the check passes.
+func (s *StandardEndpoints) AddRemediation(checkName string, policy RemediationPolicy, fn RemediationFunc) {
+	if policy.AfterFailures < 1 {
+		policy.AfterFailures = 1
+	}
+	if policy.Timeout <= 0 {
+		policy.Timeout = DefaultRemediationTimeout
+	}
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.remediations[checkName] = &remediation{policy: policy, fn: fn}
+}
+
+// RemediationStatus returns the status of the remediation for the check, false if there isn't one.
+func (s *StandardEndpoints) RemediationStatus(checkName string) (RemediationStatus, bool) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	r, ok := s.remediations[checkName]
+	if !ok {
+		return RemediationStatus{}, false
+	}
+	return r.status, true
+}
+
+// count the failures & pick the remediations to attempt, adding their status to the results.
+// must be called with the lock held, before overrides and faults are applied so only real failures count
+func (s *StandardEndpoints) remediationsDue(results []HealthCheckResult, now time.Time) []HealthCheckResult {
+	var due []HealthCheckResult
+	for i, result := range results {
+		r, ok := s.remediations[result.Name]
+		if !ok {
+			continue
+		}
+		if result.Result != HealthResultFailed {
+			if result.Result == HealthResultPassed {
+				r.status = RemediationStatus{}
+			}
+			continue
+		}
+
+		r.status.ConsecutiveFailures++
+		switch {
+		case r.status.ConsecutiveFailures < r.policy.AfterFailures:
+			r.status.Outcome = RemediationWaiting
+		case r.policy.MaxAttempts > 0 && r.status.Attempts >= r.policy.MaxAttempts:
+			r.status.Outcome = RemediationExhausted
+		case r.status.LastAttempt != nil && now.Sub(*r.status.LastAttempt) < r.policy.Cooldown:
+			r.status.Outcome = RemediationCooldown
+		default:
+			due = append(due, result)
+		}
+		results[i].Details = withDetail(result.Details, "remediation", r.status)
+	}
+	return due
+}
+
+// run the due remediations one at a time, then record the outcomes on the current report.
+// The context is that of the run, so Stop cancels a remediation in progress rather than waiting for its timeout.
+// must be called without the lock held
+func (s *StandardEndpoints) runRemediations(ctx context.Context, due []HealthCheckResult) {
+	for _, result := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		s.locker.Lock()
+		r, ok := s.remediations[result.Name]
+		s.locker.Unlock()
+		if !ok {
+			continue
+		}
+
+		start := time.Now().UTC()
+		attemptCtx, cancel := context.WithTimeout(ctx, r.policy.Timeout)
+		err := callRemediation(attemptCtx, r.fn, result)
+		cancel()
+
+		s.locker.Lock()
+		r.status.Attempts++
+		r.status.LastAttempt = &start
+		r.status.Outcome, r.status.LastError = RemediationSucceeded, ""
+		if err != nil {
+			r.status.Outcome, r.status.LastError = RemediationFailed, err.Error()
+		}
+		status := r.status
+		// copy the results so a report already handed out isn't changed
+		results := append([]HealthCheckResult(nil), s.healthReport.Results...)
+		for i := range results {
+			if results[i].Name == result.Name {
+				results[i].Details = withDetail(results[i].Details, "remediation", status)
+			}
+		}
+		s.healthReport.Results = results
+		s.locker.Unlock()
+
+		if err != nil {
+			s.log("remediation failed", "check", result.Name, "attempt", status.Attempts, "error", err.Error())
+		} else {
+			s.log("remediation succeeded", "check", result.Name, "attempt", status.Attempts)
+		}
+		s.events.record(EventRemediation, "remediation of "+result.Name+" "+status.Outcome, status)
+	}
+}
+
+// a panicking remediation counts as a failed attempt
+func callRemediation(ctx context.Context, fn RemediationFunc, result HealthCheckResult) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("remediation panicked: %v", p)
+		}
+	}()
+	return fn(ctx, result)
+}
+
+// a copy of the details with the key set, so the check's own map isn't changed
+func withDetail(details map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(details)+1)
+	for k, v := range details {
+		copied[k] = v
+	}
+	copied[key] = value
+	return copied
+}
@@ -0,0 +1,184 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-ozzo/ozzo-routing"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRemediation(t *testing.T) {
+	r := routing.New()
+	se := NewStandardEndpoints()
+	se.SetLogger(nil)
+	se.RegisterDefaultEndpoints(r)
+
+	result := HealthResultFailed
+	se.healthChecks = []HealthCheckFunc{func() HealthCheckResult {
+		return HealthCheckResult{Name: "db", Result: result}
+	}}
+	attempts := 0
+	fixErr := errors.New("still down")
+	se.AddRemediation("db", RemediationPolicy{AfterFailures: 2, MaxAttempts: 2},
+		func(ctx context.Context, result HealthCheckResult) error {
+			attempts++
+			return fixErr
+		})
+
+	status := func() RemediationStatus {
+		s, _ := se.RemediationStatus("db")
+		return s
+	}
+
+	Convey("Remediation waits for consecutive failures", t, func() {
+		se.RunHealthChecks()
+		So(attempts, ShouldEqual, 0)
+		So(status().Outcome, ShouldEqual, RemediationWaiting)
+		So(status().ConsecutiveFailures, ShouldEqual, 1)
+	})
+
+	Convey("Remediation runs once the failures reach the threshold", t, func() {
+		se.RunHealthChecks()
+		So(attempts, ShouldEqual, 1)
+		So(status().Outcome, ShouldEqual, RemediationFailed)
+		So(status().LastError, ShouldEqual, "still down")
+	})
+
+	Convey("The outcome is on the result details", t, func() {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/service/healthcheck", nil)
+		r.ServeHTTP(res, req)
+		So(res.Body.String(), ShouldContainSubstring, `"details":{"remediation":{"consecutive_failures":2,"attempts":1,"outcome":"failed"`)
+		So(res.Body.String(), ShouldContainSubstring, `"last_error":"still down"`)
+	})
+
+	Convey("Attempts stop at the maximum", t, func() {
+		se.RunHealthChecks()
+		se.RunHealthChecks()
+		So(attempts, ShouldEqual, 2)
+		So(status().Outcome, ShouldEqual, RemediationExhausted)
+	})
+
+	Convey("Attempts start over once the check passes", t, func() {
+		result = HealthResultPassed
+		se.RunHealthChecks()
+		So(status(), ShouldResemble, RemediationStatus{})
+		So(se.healthReport.Results[0].Details, ShouldBeNil)
+
+		result = HealthResultFailed
+		fixErr = nil
+		se.RunHealthChecks()
+		se.RunHealthChecks()
+		So(attempts, ShouldEqual, 3)
+		So(status().Outcome, ShouldEqual, RemediationSucceeded)
+	})
+
+	Convey("Remediation recorded in the event log", t, func() {
+		page := se.Events(0, 100)
+		last := page.Events[len(page.Events)-1]
+		So(last.Type, ShouldEqual, EventRemediation)
+		So(last.Message, ShouldEqual, "remediation of db succeeded")
+	})
+}
+
+func TestRemediationCooldown(t *testing.T) {
+	se := NewStandardEndpoints()
+	se.SetLogger(nil)
+	se.healthChecks = []HealthCheckFunc{func() HealthCheckResult {
+		return HealthCheckResult{Name: "cache", Result: HealthResultFailed}
+	}}
+	attempts := 0
+	se.AddRemediation("cache", RemediationPolicy{Cooldown: time.Hour},
+		func(ctx context.Context, result HealthCheckResult) error {
+			attempts++
+			panic("flush failed")
+		})
+
+	Convey("Attempts wait for the cooldown", t, func() {
+		se.RunHealthChecks()
+		se.RunHealthChecks()
+		So(attempts, ShouldEqual, 1)
+		s, ok := se.RemediationStatus("cache")
+		So(ok, ShouldBeTrue)
+		So(s.Outcome, ShouldEqual, RemediationCooldown)
+		So(s.LastError, ShouldEqual, "remediation panicked: flush failed")
+	})
+}
+
+func TestRemediationIgnoresOverridesAndFaults(t *testing.T) {
+	se := NewStandardEndpoints()
+	se.SetLogger(nil)
+	se.SetFaultInjectionEnabled(true)
+	result := HealthResultPassed
+	se.healthChecks = []HealthCheckFunc{func() HealthCheckResult {
+		return HealthCheckResult{Name: "db", Result: result}
+	}}
+	attempts := 0
+	se.AddRemediation("db", RemediationPolicy{}, func(ctx context.Context, result HealthCheckResult) error {
+		attempts++
+		return nil
+	})
+
+	Convey("A fault on a passing check isn't remediated", t, func() {
+		So(se.InjectFault(Fault{Kind: FaultCheck, Name: "db"}, time.Hour), ShouldBeNil)
+		se.RunHealthChecks()
+		So(attempts, ShouldEqual, 0)
+		se.ClearFaults()
+	})
+
+	Convey("A failing check overridden to pass is still remediated", t, func() {
+		result = HealthResultFailed
+		So(se.OverrideHealthCheck("db", HealthResultPassed, time.Hour, "alice"), ShouldBeNil)
+		se.RunHealthChecks()
+		So(attempts, ShouldEqual, 1)
+	})
+}
+
+func TestStopCancelsRemediation(t *testing.T) {
+	se := NewStandardEndpoints()
+	se.SetLogger(nil)
+	defer se.Close()
+
+	started := make(chan struct{})
+	se.AddRemediation("db", RemediationPolicy{Timeout: time.Hour}, func(ctx context.Context, result HealthCheckResult) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	se.SetHealthCheckFuncs(time.Hour, func() HealthCheckResult {
+		return HealthCheckResult{Name: "db", Result: HealthResultFailed}
+	})
+	<-started
+
+	Convey("Stop cancels a remediation in progress instead of waiting for its timeout", t, func() {
+		start := time.Now()
+		se.Stop()
+		So(time.Since(start), ShouldBeLessThan, time.Second)
+		s, _ := se.RemediationStatus("db")
+		So(s.Outcome, ShouldEqual, RemediationFailed)
+		So(s.LastError, ShouldEqual, context.Canceled.Error())
+	})
+}
@@ -0,0 +1,144 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"math"
+	"runtime/debug"
+	"runtime/metrics"
+	"time"
+)
+
+// RuntimeStats is the memory & GC state of the go runtime, read with runtime/metrics so it doesn't stop the world.
+type RuntimeStats struct {
+	HeapAllocBytes uint64         `json:"heap_alloc_bytes"` // live and not yet swept objects
+	HeapInuseBytes uint64         `json:"heap_inuse_bytes"` // spans with at least one object
+	HeapSysBytes   uint64         `json:"heap_sys_bytes"`   // heap memory from the OS, including released
+	TotalSysBytes  uint64         `json:"total_sys_bytes"`  // all memory mapped by the runtime
+	GCCount        uint64         `json:"gc_count"`
+	LastGC         *time.Time     `json:"last_gc,omitempty"`
+	GCPauseMillis  PauseQuantiles `json:"gc_pause_millis"`  // stop the world pauses for GC, since the process started
+	GoMemLimit     int64          `json:"gomemlimit_bytes"` // math.MaxInt64 when there is no limit
+	GOGC           int64          `json:"gogc_percent"`     // -1 when GC is off
+	CgoCalls       uint64         `json:"cgo_calls"`
+}
+
+// PauseQuantiles are approximate, the upper bound of the histogram bucket the quantile falls in.
+type PauseQuantiles struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+var runtimeMetrics = []string{
+	"/memory/classes/heap/objects:bytes",
+	"/memory/classes/heap/unused:bytes",
+	"/memory/classes/heap/free:bytes",
+	"/memory/classes/heap/released:bytes",
+	"/memory/classes/total:bytes",
+	"/gc/cycles/total:gc-cycles",
+	"/sched/pauses/total/gc:seconds",
+	"/gc/gomemlimit:bytes",
+	"/gc/gogc:percent",
+	"/cgo/go-to-c-calls:calls",
+}
+
+func readRuntimeStats() *RuntimeStats {
+	samples := make([]metrics.Sample, len(runtimeMetrics))
+	for i, name := range runtimeMetrics {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+
+	values := map[string]metrics.Value{}
+	for _, sample := range samples {
+		values[sample.Name] = sample.Value
+	}
+	// metrics not supported by this go version are KindBad and read as 0
+	uint64Value := func(name string) uint64 {
+		if v := values[name]; v.Kind() == metrics.KindUint64 {
+			return v.Uint64()
+		}
+		return 0
+	}
+
+	objects, unused := uint64Value("/memory/classes/heap/objects:bytes"), uint64Value("/memory/classes/heap/unused:bytes")
+	stats := &RuntimeStats{
+		HeapAllocBytes: objects,
+		HeapInuseBytes: objects + unused,
+		HeapSysBytes: objects + unused + uint64Value("/memory/classes/heap/free:bytes") +
+			uint64Value("/memory/classes/heap/released:bytes"),
+		TotalSysBytes: uint64Value("/memory/classes/total:bytes"),
+		GCCount:       uint64Value("/gc/cycles/total:gc-cycles"),
+		GoMemLimit:    int64(uint64Value("/gc/gomemlimit:bytes")),
+		GOGC:          int64(uint64Value("/gc/gogc:percent")),
+		CgoCalls:      uint64Value("/cgo/go-to-c-calls:calls"),
+	}
+	if v := values["/sched/pauses/total/gc:seconds"]; v.Kind() == metrics.KindFloat64Histogram {
+		stats.GCPauseMillis = pauseQuantiles(v.Float64Histogram())
+	}
+
+	// doesn't stop the world either, runtime/metrics has no last GC time
+	gcStats := debug.GCStats{}
+	debug.ReadGCStats(&gcStats)
+	if !gcStats.LastGC.IsZero() {
+		last := gcStats.LastGC.UTC()
+		stats.LastGC = &last
+	}
+	return stats
+}
+
+func pauseQuantiles(h *metrics.Float64Histogram) PauseQuantiles {
+	var total uint64
+	for _, count := range h.Counts {
+		total += count
+	}
+	if total == 0 {
+		return PauseQuantiles{}
+	}
+
+	// bucket i covers Buckets[i] to Buckets[i+1], the last may be +Inf
+	bound := func(i int) float64 {
+		upper := h.Buckets[i+1]
+		if math.IsInf(upper, 1) {
+			upper = h.Buckets[i]
+		}
+		return upper * 1000
+	}
+	quantile := func(q float64) float64 {
+		rank := uint64(math.Ceil(q * float64(total)))
+		var seen uint64
+		for i, count := range h.Counts {
+			seen += count
+			if seen >= rank {
+				return bound(i)
+			}
+		}
+		return 0
+	}
+	quantiles := PauseQuantiles{P50: quantile(0.5), P95: quantile(0.95), P99: quantile(0.99)}
+	for i := len(h.Counts) - 1; i >= 0; i-- {
+		if h.Counts[i] > 0 {
+			quantiles.Max = bound(i)
+			break
+		}
+	}
+	return quantiles
+}
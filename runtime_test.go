@@ -0,0 +1,61 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRuntimeStats(t *testing.T) {
+	Convey("Pause quantiles are read from the histogram", t, func() {
+		h := &metrics.Float64Histogram{
+			Counts:  []uint64{50, 40, 9, 1},
+			Buckets: []float64{0, 0.001, 0.002, 0.005, math.Inf(1)},
+		}
+		So(pauseQuantiles(h), ShouldResemble, PauseQuantiles{P50: 1, P95: 5, P99: 5, Max: 5})
+		So(pauseQuantiles(&metrics.Float64Histogram{Counts: []uint64{0}, Buckets: []float64{0, 1}}),
+			ShouldResemble, PauseQuantiles{})
+	})
+
+	Convey("The runtime section reflects the GC", t, func() {
+		runtime.GC()
+		stats := readRuntimeStats()
+		So(stats.GCCount, ShouldBeGreaterThan, 0)
+		So(stats.LastGC, ShouldNotBeNil)
+		So(stats.HeapAllocBytes, ShouldBeGreaterThan, 0)
+		So(stats.HeapInuseBytes, ShouldBeGreaterThanOrEqualTo, stats.HeapAllocBytes)
+		So(stats.HeapSysBytes, ShouldBeGreaterThanOrEqualTo, stats.HeapInuseBytes)
+		So(stats.TotalSysBytes, ShouldBeGreaterThanOrEqualTo, stats.HeapSysBytes)
+		So(stats.GCPauseMillis.Max, ShouldBeGreaterThan, 0)
+		So(stats.GOGC, ShouldNotEqual, 0)
+		So(stats.GoMemLimit, ShouldBeGreaterThan, 0)
+	})
+
+	Convey("The status includes the runtime", t, func() {
+		status := NewStandardEndpoints().generateStatus()
+		So(status.VMVersion, ShouldEqual, runtime.Version())
+		So(status.VMName, ShouldEqual, "go")
+		So(status.Runtime, ShouldNotBeNil)
+	})
+}
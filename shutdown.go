@@ -0,0 +1,164 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// LifecycleState
+// "running", "draining", "stopping", "stopped"
+const (
+	LifecycleRunning  = "running"  // serving traffic
+	LifecycleDraining = "draining" // GTG is failing so the load balancer stops sending traffic
+	LifecycleStopping = "stopping" // the http.Server is shutting down
+	LifecycleStopped  = "stopped"  // the http.Server has shut down
+)
+
+var (
+	DefaultDrainPeriod     = time.Duration(10) * time.Second
+	DefaultShutdownTimeout = time.Duration(20) * time.Second
+)
+
+// GracefulServer runs an http.Server until it receives a signal, then takes the instance
+// out of rotation by failing GTG for the drain period before shutting the server down.
+type GracefulServer struct {
+	Server          *http.Server
+	Endpoints       *StandardEndpoints
+	DrainPeriod     time.Duration // how long GTG fails before the server stops accepting connections
+	ShutdownTimeout time.Duration // how long in-flight requests are given to complete
+	Signals         []os.Signal   // the signals that start the shutdown, SIGINT and SIGTERM by default
+}
+
+func NewGracefulServer(server *http.Server, endpoints *StandardEndpoints) *GracefulServer {
+	return &GracefulServer{
+		Server:          server,
+		Endpoints:       endpoints,
+		DrainPeriod:     DefaultDrainPeriod,
+		ShutdownTimeout: DefaultShutdownTimeout,
+		Signals:         []os.Signal{os.Interrupt, syscall.SIGTERM},
+	}
+}
+
+// ListenAndServe blocks until the server fails to start or a signal has been received and shutdown completed.
+func (g *GracefulServer) ListenAndServe() error {
+	addr := g.Server.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return g.Serve(l)
+}
+
+// Serve is the same as ListenAndServe but accepts connections on the listener.
+// The listener's address is shown in the status.
+func (g *GracefulServer) Serve(l net.Listener) error {
+	g.Endpoints.AddListenAddress(l.Addr().String())
+	return g.serve(func() error { return g.Server.Serve(l) })
+}
+
+func (g *GracefulServer) serve(start func() error) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, g.Signals...)
+	defer signal.Stop(signals)
+
+	errs := make(chan error, 1)
+	go func() { errs <- start() }()
+
+	select {
+	case err := <-errs:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil // Shutdown was called directly
+		}
+		return err
+	case sig := <-signals:
+		g.Endpoints.log("shutdown signal received", "signal", sig.String())
+	}
+
+	err := g.Shutdown(context.Background())
+	<-errs
+	return err
+}
+
+// Shutdown drains then stops the server and closes the endpoints, stopping the health checks.
+// Cancelling the context cuts the drain period short and forces the server to close.
+func (g *GracefulServer) Shutdown(ctx context.Context) error {
+	s := g.Endpoints
+	s.setLifecycleState(LifecycleDraining)
+	s.log("draining", "drain_period", g.DrainPeriod.String())
+	select {
+	case <-time.After(g.DrainPeriod):
+	case <-ctx.Done():
+	}
+
+	s.setLifecycleState(LifecycleStopping)
+	s.log("stopping http server", "timeout", g.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(ctx, g.ShutdownTimeout)
+	defer cancel()
+	err := g.Server.Shutdown(shutdownCtx)
+	if err != nil {
+		g.Server.Close()
+	}
+
+	if closeErr := s.Close(); err == nil {
+		err = closeErr
+	}
+	s.setLifecycleState(LifecycleStopped)
+	s.log("stopped")
+	return err
+}
+
+// Drain takes the instance out of rotation by failing GTG, without stopping anything.
+func (s *StandardEndpoints) Drain() {
+	s.setLifecycleState(LifecycleDraining)
+}
+
+func (s *StandardEndpoints) setLifecycleState(state string) {
+	s.locker.Lock()
+	if state != LifecycleRunning && !s.draining {
+		s.draining = true
+		s.Status.DrainingSince = time.Now().UTC().Format(time.RFC3339)
+	}
+	previous := s.Status.LifecycleState
+	s.Status.LifecycleState = state
+	s.locker.Unlock()
+	if previous != state {
+		s.events.record(EventLifecycle, previous+" -> "+state, nil)
+	}
+}
+
+// log at info level if there is a logger
+func (s *StandardEndpoints) log(msg string, args ...interface{}) {
+	s.locker.Lock()
+	logger := s.logger
+	s.locker.Unlock()
+	if logger != nil {
+		logger.Info(msg, args...)
+	}
+}
@@ -0,0 +1,108 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-ozzo/ozzo-routing"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func startGracefulServer(signals ...os.Signal) (*GracefulServer, string, chan error) {
+	r := routing.New()
+	se := NewStandardEndpoints()
+	se.SetLogger(nil)
+	se.RegisterDefaultEndpoints(r)
+
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	g := NewGracefulServer(&http.Server{Handler: r}, se)
+	g.DrainPeriod = time.Duration(100) * time.Millisecond
+	if len(signals) > 0 {
+		g.Signals = signals
+	}
+	done := make(chan error, 1)
+	go func() { done <- g.Serve(l) }()
+	return g, "http://" + l.Addr().String(), done
+}
+
+func get(url string) (int, string) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return 0, ""
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	g, url, done := startGracefulServer()
+
+	Convey("GTG is OK while running", t, func() {
+		code, _ := get(url + "/service/healthcheck/gtg")
+		So(code, ShouldEqual, http.StatusOK)
+		_, body := get(url + "/service/status")
+		So(body, ShouldContainSubstring, `"lifecycle_state":"running"`)
+	})
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- g.Shutdown(context.Background()) }()
+	time.Sleep(time.Duration(20) * time.Millisecond)
+
+	Convey("GTG fails while draining but the server still responds", t, func() {
+		code, _ := get(url + "/service/healthcheck/gtg")
+		So(code, ShouldEqual, http.StatusServiceUnavailable)
+		code, body := get(url + "/service/status")
+		So(code, ShouldEqual, http.StatusOK)
+		So(body, ShouldContainSubstring, `"lifecycle_state":"draining"`)
+		So(body, ShouldContainSubstring, `"draining_since"`)
+	})
+
+	Convey("Server is stopped after the drain period", t, func() {
+		So(<-shutdown, ShouldBeNil)
+		So(<-done, ShouldBeNil)
+		So(g.Endpoints.generateStatus().LifecycleState, ShouldEqual, LifecycleStopped)
+		code, _ := get(url + "/service/status")
+		So(code, ShouldEqual, 0)
+	})
+}
+
+func TestGracefulShutdownOnSignal(t *testing.T) {
+	g, url, done := startGracefulServer(syscall.SIGUSR1)
+	for i := 0; i < 100; i++ {
+		if code, _ := get(url + "/service/healthcheck/gtg"); code == http.StatusOK {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+
+	Convey("Signal drains and stops the server", t, func() {
+		So(<-done, ShouldBeNil)
+		So(g.Endpoints.generateStatus().LifecycleState, ShouldEqual, LifecycleStopped)
+	})
+}
@@ -0,0 +1,127 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// PreviousRun is what the state file recorded about the last time the process ran.
+type PreviousRun struct {
+	RestartCount  int                `json:"restart_count"` // the number of times the process has started with this state file before
+	StartedAt     time.Time          `json:"started_at"`
+	Uptime        string             `json:"uptime"`         // up to the shutdown, or the last write of the state file if it wasn't clean
+	CleanShutdown bool               `json:"clean_shutdown"` // false after a crash or kill
+	LastReport    *HealthCheckReport `json:"last_report,omitempty"`
+}
+
+// the contents of the state file
+type persistedState struct {
+	StartTime     time.Time          `json:"start_time"`
+	LastWritten   time.Time          `json:"last_written"`
+	RestartCount  int                `json:"restart_count"`
+	CleanShutdown bool               `json:"clean_shutdown"`
+	LastReport    *HealthCheckReport `json:"last_report,omitempty"`
+}
+
+type stateFile struct {
+	locker       *sync.Mutex
+	path         string
+	restartCount int
+}
+
+// Keep the last health report, start time and whether shutdown was clean in a file, so they survive a restart.
+// The previous run read from the file is shown in the status as "previous_run". The file is rewritten
+// after each health check run and marked clean by Close.
+func (s *StandardEndpoints) SetStateFile(path string) error {
+	var previous *persistedState
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		previous = &persistedState{}
+		if err := json.Unmarshal(data, previous); err != nil {
+			return err
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+
+	state := &stateFile{locker: &sync.Mutex{}, path: path}
+	s.locker.Lock()
+	if previous != nil {
+		state.restartCount = previous.RestartCount + 1
+		s.Status.PreviousRun = &PreviousRun{RestartCount: state.restartCount, StartedAt: previous.StartTime,
+			Uptime:        previous.LastWritten.Sub(previous.StartTime).String(),
+			CleanShutdown: previous.CleanShutdown, LastReport: previous.LastReport}
+	}
+	s.stateFile = state
+	s.locker.Unlock()
+	return s.writeState(false)
+}
+
+// write the state file if there is one, clean marks the shutdown as clean
+func (s *StandardEndpoints) writeState(clean bool) error {
+	s.locker.Lock()
+	state := s.stateFile
+	persisted := persistedState{StartTime: s.Status.UpSinceTime, CleanShutdown: clean}
+	if !s.healthReport.Timestamp.IsZero() {
+		report := s.effectiveReport(time.Now().UTC())
+		persisted.LastReport = &report
+	}
+	s.locker.Unlock()
+	if state == nil {
+		return nil
+	}
+
+	state.locker.Lock()
+	defer state.locker.Unlock()
+	persisted.RestartCount = state.restartCount
+	persisted.LastWritten = time.Now().UTC()
+	data, err := json.Marshal(persisted)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(state.path, data)
+}
+
+// write to a temporary file in the same directory then rename it, so a crash never leaves a partial file
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
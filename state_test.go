@@ -0,0 +1,96 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-ozzo/ozzo-routing"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "se4.state")
+	check := func() HealthCheckResult {
+		return HealthCheckResult{Name: "db", Result: HealthResultFailed}
+	}
+
+	Convey("The first run has no previous run", t, func() {
+		se := NewStandardEndpoints()
+		se.SetLogger(nil)
+		So(se.SetStateFile(path), ShouldBeNil)
+		So(se.generateStatus().PreviousRun, ShouldBeNil)
+
+		se.healthChecks = []HealthCheckFunc{check}
+		se.RunHealthChecks()
+		// no Close, as if the process crashed
+	})
+
+	Convey("A restart after a crash shows the last report", t, func() {
+		se := NewStandardEndpoints()
+		se.SetLogger(nil)
+		So(se.SetStateFile(path), ShouldBeNil)
+		previous := se.generateStatus().PreviousRun
+		So(previous, ShouldNotBeNil)
+		So(previous.RestartCount, ShouldEqual, 1)
+		So(previous.CleanShutdown, ShouldBeFalse)
+		So(previous.LastReport, ShouldNotBeNil)
+		So(previous.LastReport.Results[0].Name, ShouldEqual, "db")
+		So(previous.LastReport.Results[0].Result, ShouldEqual, HealthResultFailed)
+		So(se.Close(), ShouldBeNil)
+	})
+
+	Convey("A restart after Close shows a clean shutdown", t, func() {
+		r := routing.New()
+		se := NewStandardEndpoints()
+		se.SetLogger(nil)
+		se.RegisterDefaultEndpoints(r)
+		So(se.SetStateFile(path), ShouldBeNil)
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/service/status", nil)
+		r.ServeHTTP(res, req)
+		var status struct {
+			PreviousRun PreviousRun `json:"previous_run"`
+		}
+		So(json.Unmarshal(res.Body.Bytes(), &status), ShouldBeNil)
+		So(status.PreviousRun.RestartCount, ShouldEqual, 2)
+		So(status.PreviousRun.CleanShutdown, ShouldBeTrue)
+		// no health checks ran in the previous run
+		So(status.PreviousRun.LastReport, ShouldBeNil)
+		_, err := time.ParseDuration(status.PreviousRun.Uptime)
+		So(err, ShouldBeNil)
+	})
+
+	Convey("No temporary files are left behind", t, func() {
+		entries, _ := os.ReadDir(filepath.Dir(path))
+		So(len(entries), ShouldEqual, 1)
+	})
+
+	Convey("A corrupt state file is an error", t, func() {
+		os.WriteFile(path, []byte("{"), 0644)
+		So(NewStandardEndpoints().SetStateFile(path), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,168 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+var (
+	DefaultHealthStatsWindow = 100 // the number of most recent runs per check the stats are computed from
+)
+
+// HealthCheckStats summarises the recent runs of a single health check.
+// Runs, failures, ratio and percentiles cover the rolling window, the times and outage cover the life of the process.
+// Only runs that passed or failed are counted, using the check's own result rather than an override or fault.
+type HealthCheckStats struct {
+	Name          string         `json:"test_name"`
+	Runs          int            `json:"runs"`
+	Failures      int            `json:"failures"`
+	SuccessRatio  float64        `json:"success_ratio"` // passed runs / runs
+	P50Millis     float64        `json:"p50_duration_millis"`
+	P95Millis     float64        `json:"p95_duration_millis"`
+	P99Millis     float64        `json:"p99_duration_millis"`
+	LastSuccess   *time.Time     `json:"last_success,omitempty"`
+	LastFailure   *time.Time     `json:"last_failure,omitempty"`
+	LongestOutage ReportDuration `json:"longest_outage"` // the longest time between a failure and the next success
+	CurrentOutage ReportDuration `json:"current_outage"` // zero unless the check is failing
+}
+
+type healthCheckSample struct {
+	durationMillis float64
+	failed         bool
+}
+
+type healthCheckHistory struct {
+	samples       []healthCheckSample // ring buffer
+	next          int
+	lastSuccess   time.Time
+	lastFailure   time.Time
+	outageStart   time.Time
+	longestOutage time.Duration
+}
+
+// record a passed or failed run
+func (h *healthCheckHistory) record(result HealthCheckResult, at time.Time) {
+	failed := result.Result == HealthResultFailed
+	sample := healthCheckSample{durationMillis: result.DurationMillis, failed: failed}
+	if len(h.samples) < DefaultHealthStatsWindow {
+		h.samples = append(h.samples, sample)
+	} else {
+		h.samples[h.next%len(h.samples)] = sample
+	}
+	h.next++
+
+	if failed {
+		h.lastFailure = at
+		if h.outageStart.IsZero() {
+			h.outageStart = at
+		}
+	} else {
+		h.lastSuccess = at
+		if !h.outageStart.IsZero() {
+			if outage := at.Sub(h.outageStart); outage > h.longestOutage {
+				h.longestOutage = outage
+			}
+			h.outageStart = time.Time{}
+		}
+	}
+}
+
+func (h *healthCheckHistory) stats(name string, now time.Time) HealthCheckStats {
+	stats := HealthCheckStats{Name: name, Runs: len(h.samples)}
+	durations := make([]float64, 0, len(h.samples))
+	for _, sample := range h.samples {
+		if sample.failed {
+			stats.Failures++
+		}
+		durations = append(durations, sample.durationMillis)
+	}
+	if stats.Runs > 0 {
+		stats.SuccessRatio = float64(stats.Runs-stats.Failures) / float64(stats.Runs)
+	}
+	sort.Float64s(durations)
+	stats.P50Millis = percentile(durations, 50)
+	stats.P95Millis = percentile(durations, 95)
+	stats.P99Millis = percentile(durations, 99)
+
+	if !h.lastSuccess.IsZero() {
+		t := h.lastSuccess
+		stats.LastSuccess = &t
+	}
+	if !h.lastFailure.IsZero() {
+		t := h.lastFailure
+		stats.LastFailure = &t
+	}
+	stats.LongestOutage = ReportDuration(h.longestOutage)
+	if !h.outageStart.IsZero() {
+		current := now.Sub(h.outageStart)
+		stats.CurrentOutage = ReportDuration(current)
+		if current > h.longestOutage {
+			stats.LongestOutage = ReportDuration(current)
+		}
+	}
+	return stats
+}
+
+// nearest rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// record the results that passed or failed, skipping paused and running checks.
+// Must be called with the lock held, before overrides and faults are applied.
+func (s *StandardEndpoints) recordHealthStats(results []HealthCheckResult, timestamp time.Time) {
+	for _, result := range results {
+		if result.Result != HealthResultPassed && result.Result != HealthResultFailed {
+			continue
+		}
+		h, ok := s.checkHistory[result.Name]
+		if !ok {
+			h = &healthCheckHistory{}
+			s.checkHistory[result.Name] = h
+		}
+		at := result.Timestamp
+		if at.IsZero() {
+			at = timestamp
+		}
+		h.record(result, at)
+	}
+}
+
+// HealthCheckStats returns the statistics of each health check that has run, ordered by name.
+func (s *StandardEndpoints) HealthCheckStats() []HealthCheckStats {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	now := time.Now().UTC()
+	stats := make([]HealthCheckStats, 0, len(s.checkHistory))
+	for name, h := range s.checkHistory {
+		stats = append(stats, h.stats(name, now))
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
@@ -0,0 +1,146 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-ozzo/ozzo-routing"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHealthCheckStats(t *testing.T) {
+	start := time.Date(2017, 3, 18, 0, 0, 0, 0, time.UTC)
+	h := &healthCheckHistory{}
+	for i := 1; i <= 10; i++ {
+		result := HealthCheckResult{Name: "db", DurationMillis: float64(i), Result: HealthResultPassed}
+		if i >= 4 && i <= 6 {
+			result.Result = HealthResultFailed
+		}
+		h.record(result, start.Add(time.Duration(i)*time.Second))
+	}
+
+	Convey("Stats over the window", t, func() {
+		stats := h.stats("db", start.Add(time.Minute))
+		So(stats.Runs, ShouldEqual, 10)
+		So(stats.Failures, ShouldEqual, 3)
+		So(stats.SuccessRatio, ShouldAlmostEqual, 0.7)
+		So(stats.P50Millis, ShouldEqual, 5)
+		So(stats.P95Millis, ShouldEqual, 10)
+		So(stats.P99Millis, ShouldEqual, 10)
+		So(*stats.LastSuccess, ShouldEqual, start.Add(10*time.Second))
+		So(*stats.LastFailure, ShouldEqual, start.Add(6*time.Second))
+		So(time.Duration(stats.LongestOutage), ShouldEqual, 3*time.Second)
+		So(stats.CurrentOutage, ShouldEqual, 0)
+	})
+
+	Convey("Window is bounded", t, func() {
+		for i := 0; i < DefaultHealthStatsWindow; i++ {
+			h.record(HealthCheckResult{Result: HealthResultFailed}, start.Add(time.Hour))
+		}
+		stats := h.stats("db", start.Add(2*time.Hour))
+		So(stats.Runs, ShouldEqual, DefaultHealthStatsWindow)
+		So(stats.SuccessRatio, ShouldEqual, 0)
+		So(time.Duration(stats.CurrentOutage), ShouldEqual, time.Hour)
+		So(time.Duration(stats.LongestOutage), ShouldEqual, time.Hour)
+	})
+}
+
+func TestHealthCheckStatsEndpoint(t *testing.T) {
+	r := routing.New()
+	se := NewStandardEndpoints()
+	se.SetLogger(nil)
+	se.RegisterDefaultEndpoints(r)
+
+	ran := make(chan struct{}, 1)
+	se.SetHealthCheckFuncs(time.Hour, func() HealthCheckResult {
+		defer func() { ran <- struct{}{} }()
+		return HealthCheckResult{Name: "cache", Result: HealthResultPassed, DurationMillis: 1.5}
+	})
+	defer se.SetHealthCheckFuncs(0)
+	<-ran
+	for i := 0; i < 100 && len(se.HealthCheckStats()) == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	Convey("Get Healthcheck Stats", t, func() {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/service/healthcheck/stats", nil)
+		r.ServeHTTP(res, req)
+		So(res.Code, ShouldEqual, http.StatusOK)
+		So(contentType(res), ShouldStartWith, "application/json")
+		So(res.Body.String(), ShouldContainSubstring, `"test_name":"cache"`)
+		So(res.Body.String(), ShouldContainSubstring, `"runs":1`)
+		So(res.Body.String(), ShouldContainSubstring, `"p50_duration_millis":1.5`)
+	})
+}
+
+func TestHealthCheckStatsIgnorePausedAndOverridden(t *testing.T) {
+	se := NewStandardEndpoints()
+	se.SetLogger(nil)
+	se.healthChecks = []HealthCheckFunc{func() HealthCheckResult {
+		return HealthCheckResult{Name: "db", Result: HealthResultFailed}
+	}}
+
+	Convey("Paused runs aren't counted", t, func() {
+		se.RunHealthChecks()
+		se.PauseHealthCheck("db")
+		for i := 0; i < 9; i++ {
+			se.RunHealthChecks()
+		}
+		stats := se.HealthCheckStats()
+		So(stats[0].Runs, ShouldEqual, 1)
+		So(stats[0].SuccessRatio, ShouldEqual, 0)
+	})
+
+	Convey("Overridden runs count the check's own result", t, func() {
+		se.ResumeHealthCheck("db")
+		So(se.OverrideHealthCheck("db", HealthResultPassed, time.Hour, "alice"), ShouldBeNil)
+		se.RunHealthChecks()
+		stats := se.HealthCheckStats()
+		So(stats[0].Runs, ShouldEqual, 2)
+		So(stats[0].Failures, ShouldEqual, 2)
+		So(stats[0].LastSuccess, ShouldBeNil)
+	})
+}
+
+func TestHealthCheckStatsSkipWarmUp(t *testing.T) {
+	se := NewStandardEndpoints()
+	se.SetLogger(nil)
+	se.AddWarmUpTask("cache", func(ctx context.Context) error { return errors.New("cold") })
+	se.RunWarmUp(context.Background())
+	se.healthChecks = []HealthCheckFunc{func() HealthCheckResult {
+		return HealthCheckResult{Name: "db", Result: HealthResultPassed}
+	}}
+
+	Convey("Warm-up tasks are in the report but not the stats", t, func() {
+		se.RunHealthChecks()
+		se.RunHealthChecks()
+		So(se.publishHealthReport().Results[1].Name, ShouldEqual, "warm-up: cache")
+		stats := se.HealthCheckStats()
+		So(len(stats), ShouldEqual, 1)
+		So(stats[0].Name, ShouldEqual, "db")
+		So(stats[0].Runs, ShouldEqual, 2)
+	})
+}
@@ -0,0 +1,165 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	routing "github.com/go-ozzo/ozzo-routing"
+)
+
+const (
+	MIME_EVENT_STREAM = "text/event-stream"
+)
+
+var (
+	DefaultHealthStreamKeepAlive = time.Duration(15) * time.Second
+	DefaultHealthStreamHistory   = 32 // the number of reports kept for Last-Event-ID resume
+)
+
+// healthStream fans out health reports to Server-Sent Event subscribers.
+// It has its own lock so slow clients never hold up the StandardEndpoints lock.
+type healthStream struct {
+	locker      *sync.Mutex
+	lastID      uint64
+	history     []healthStreamEvent // oldest first
+	subscribers map[chan struct{}]struct{}
+}
+
+type healthStreamEvent struct {
+	id   uint64
+	data []byte
+}
+
+func newHealthStream() *healthStream {
+	return &healthStream{locker: &sync.Mutex{}, subscribers: map[chan struct{}]struct{}{}}
+}
+
+func (h *healthStream) publish(report HealthCheckReport) {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return
+	}
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	h.lastID++
+	h.history = append(h.history, healthStreamEvent{id: h.lastID, data: data})
+	if len(h.history) > DefaultHealthStreamHistory {
+		h.history = h.history[len(h.history)-DefaultHealthStreamHistory:]
+	}
+	// subscribers that haven't caught up yet already have a pending wake up
+	for ch := range h.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (h *healthStream) subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	h.subscribers[ch] = struct{}{}
+	return ch, func() {
+		h.locker.Lock()
+		defer h.locker.Unlock()
+		delete(h.subscribers, ch)
+	}
+}
+
+// events after the id, or just the latest when the id isn't in the history, e.g. it is 0,
+// has been trimmed or came from before a restart as ids start again in each process
+func (h *healthStream) since(id uint64) []healthStreamEvent {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	if len(h.history) == 0 {
+		return nil
+	}
+	for i, e := range h.history {
+		if e.id == id {
+			return append([]healthStreamEvent(nil), h.history[i+1:]...)
+		}
+	}
+	return h.history[len(h.history)-1:]
+}
+
+func (h *healthStream) numSubscribers() int {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	return len(h.subscribers)
+}
+
+// Streams the health report as Server-Sent Events each time a check changes state.
+// Clients reconnecting with a Last-Event-ID header are sent the reports they missed.
+func (s *StandardEndpoints) serveHealthStream(c *routing.Context) error {
+	flusher, ok := c.Response.(http.Flusher)
+	if !ok {
+		return routing.NewHTTPError(http.StatusNotImplemented, "streaming is not supported")
+	}
+
+	lastID, _ := strconv.ParseUint(c.Request.Header.Get("Last-Event-ID"), 10, 64)
+	notify, unsubscribe := s.healthStream.subscribe()
+	defer unsubscribe()
+
+	header := c.Response.Header()
+	header.Set("Content-Type", MIME_EVENT_STREAM)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	c.Response.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	send := func() error {
+		for _, e := range s.healthStream.since(lastID) {
+			if _, err := fmt.Fprintf(c.Response, "id: %d\nevent: report\ndata: %s\n\n", e.id, e.data); err != nil {
+				return err
+			}
+			lastID = e.id
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	keepAlive := time.NewTicker(DefaultHealthStreamKeepAlive)
+	defer keepAlive.Stop()
+
+	if err := send(); err != nil {
+		return nil
+	}
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return nil
+		case <-notify:
+			if err := send(); err != nil {
+				return nil
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(c.Response, ": keepalive\n\n"); err != nil {
+				return nil
+			}
+			flusher.Flush()
+		}
+	}
+}
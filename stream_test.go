@@ -0,0 +1,123 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-ozzo/ozzo-routing"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// read lines from the stream up to and including the next blank line
+func readStreamEvent(r *bufio.Reader) []string {
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return lines
+		}
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestHealthStream(t *testing.T) {
+	r := routing.New()
+	se := NewStandardEndpoints()
+	se.SetLogger(nil)
+	se.RegisterDefaultEndpoints(r)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	se.healthStream.publish(HealthCheckReport{Results: []HealthCheckResult{{Name: "first", Result: HealthResultPassed}}})
+
+	Convey("Stream sends the latest report then new reports", t, func() {
+		resp, err := http.Get(server.URL + "/service/healthcheck/stream")
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		So(resp.Header.Get("Content-Type"), ShouldEqual, MIME_EVENT_STREAM)
+
+		reader := bufio.NewReader(resp.Body)
+		event := readStreamEvent(reader)
+		So(event, ShouldContain, "id: 1")
+		So(event, ShouldContain, "event: report")
+		So(strings.Join(event, "\n"), ShouldContainSubstring, `"test_name":"first"`)
+
+		se.healthStream.publish(HealthCheckReport{Results: []HealthCheckResult{{Name: "second", Result: HealthResultFailed}}})
+		event = readStreamEvent(reader)
+		So(event, ShouldContain, "id: 2")
+		So(strings.Join(event, "\n"), ShouldContainSubstring, `"test_name":"second"`)
+	})
+
+	Convey("Stream resumes from Last-Event-ID", t, func() {
+		se.healthStream.publish(HealthCheckReport{Results: []HealthCheckResult{{Name: "third", Result: HealthResultPassed}}})
+
+		req, _ := http.NewRequest("GET", server.URL+"/service/healthcheck/stream", nil)
+		req.Header.Set("Last-Event-ID", "1")
+		resp, err := http.DefaultClient.Do(req)
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+
+		reader := bufio.NewReader(resp.Body)
+		So(readStreamEvent(reader), ShouldContain, "id: 2")
+		So(readStreamEvent(reader), ShouldContain, "id: 3")
+	})
+
+	Convey("Stream sends the latest report for an unknown Last-Event-ID, e.g. from before a restart", t, func() {
+		req, _ := http.NewRequest("GET", server.URL+"/service/healthcheck/stream", nil)
+		req.Header.Set("Last-Event-ID", "500")
+		resp, err := http.DefaultClient.Do(req)
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+
+		event := readStreamEvent(bufio.NewReader(resp.Body))
+		So(event, ShouldContain, "id: 3")
+		So(strings.Join(event, "\n"), ShouldContainSubstring, `"test_name":"third"`)
+	})
+
+	Convey("Health check state changes are published", t, func() {
+		se.SetHealthCheckFuncs(time.Hour, func() HealthCheckResult {
+			return HealthCheckResult{Name: "scheduled", Result: HealthResultPassed}
+		})
+		defer se.SetHealthCheckFuncs(0)
+
+		for i := 0; i < 100 && len(se.healthStream.since(3)) == 0; i++ {
+			time.Sleep(time.Millisecond)
+		}
+		events := se.healthStream.since(3)
+		So(len(events), ShouldEqual, 1)
+		So(string(events[0].data), ShouldContainSubstring, `"test_name":"scheduled"`)
+	})
+
+	Convey("Subscribers are removed when clients disconnect", t, func() {
+		for i := 0; i < 100 && se.healthStream.numSubscribers() > 0; i++ {
+			time.Sleep(time.Millisecond)
+		}
+		So(se.healthStream.numSubscribers(), ShouldEqual, 0)
+	})
+}
@@ -0,0 +1,104 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	sigar "github.com/cloudfoundry/gosigar"
+)
+
+var (
+	// the mount points reported in the system disks, see SetDiskMounts
+	DefaultDiskMounts = []string{"/"}
+)
+
+// SystemInfo is the load and resources of the host, a section that is left out couldn't be read.
+type SystemInfo struct {
+	LoadAverage *LoadAverage `json:"load_average,omitempty"`
+	Memory      *MemoryUsage `json:"memory,omitempty"`
+	Swap        *SwapUsage   `json:"swap,omitempty"`
+	Disks       []DiskUsage  `json:"disks"`
+}
+
+type LoadAverage struct {
+	One     float64 `json:"one"`
+	Five    float64 `json:"five"`
+	Fifteen float64 `json:"fifteen"`
+}
+
+type MemoryUsage struct {
+	TotalBytes      uint64 `json:"total_bytes"`
+	UsedBytes       uint64 `json:"used_bytes"`
+	FreeBytes       uint64 `json:"free_bytes"`
+	ActualUsedBytes uint64 `json:"actual_used_bytes"` // not counting buffers & cache
+	ActualFreeBytes uint64 `json:"actual_free_bytes"` // including buffers & cache
+}
+
+type SwapUsage struct {
+	TotalBytes uint64 `json:"total_bytes"`
+	UsedBytes  uint64 `json:"used_bytes"`
+	FreeBytes  uint64 `json:"free_bytes"`
+}
+
+type DiskUsage struct {
+	Mount       string  `json:"mount"`
+	TotalBytes  uint64  `json:"total_bytes"`
+	UsedBytes   uint64  `json:"used_bytes"`
+	AvailBytes  uint64  `json:"avail_bytes"` // free for unprivileged users
+	UsedPercent float64 `json:"used_percent"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// Set the mount points reported in the system disks, DefaultDiskMounts by default.
+func (s *StandardEndpoints) SetDiskMounts(mounts ...string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.diskMounts = append([]string(nil), mounts...)
+}
+
+func readSystemInfo(mounts []string) *SystemInfo {
+	concreteSigar := sigar.ConcreteSigar{}
+	info := &SystemInfo{Disks: []DiskUsage{}}
+	if avg, err := concreteSigar.GetLoadAverage(); err == nil {
+		info.LoadAverage = &LoadAverage{One: avg.One, Five: avg.Five, Fifteen: avg.Fifteen}
+	}
+	if mem, err := concreteSigar.GetMem(); err == nil {
+		info.Memory = &MemoryUsage{TotalBytes: mem.Total, UsedBytes: mem.Used, FreeBytes: mem.Free,
+			ActualUsedBytes: mem.ActualUsed, ActualFreeBytes: mem.ActualFree}
+	}
+	if swap, err := concreteSigar.GetSwap(); err == nil {
+		info.Swap = &SwapUsage{TotalBytes: swap.Total, UsedBytes: swap.Used, FreeBytes: swap.Free}
+	}
+	for _, mount := range mounts {
+		info.Disks = append(info.Disks, readDiskUsage(mount))
+	}
+	return info
+}
+
+func readDiskUsage(mount string) DiskUsage {
+	disk := DiskUsage{Mount: mount}
+	usage := sigar.FileSystemUsage{}
+	if err := usage.Get(mount); err != nil {
+		disk.Error = err.Error()
+		return disk
+	}
+	// gosigar reports kilobytes
+	disk.TotalBytes, disk.UsedBytes, disk.AvailBytes = usage.Total*1024, usage.Used*1024, usage.Avail*1024
+	disk.UsedPercent = usage.UsePercent()
+	return disk
+}
@@ -0,0 +1,50 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSystemInfo(t *testing.T) {
+	Convey("The host load, memory, swap and disks are read", t, func() {
+		info := readSystemInfo([]string{"/", "/does/not/exist"})
+		So(info.LoadAverage, ShouldNotBeNil)
+		So(info.Memory, ShouldNotBeNil)
+		So(info.Memory.TotalBytes, ShouldBeGreaterThan, 0)
+		So(info.Memory.UsedBytes+info.Memory.FreeBytes, ShouldEqual, info.Memory.TotalBytes)
+		So(info.Swap, ShouldNotBeNil)
+		So(len(info.Disks), ShouldEqual, 2)
+		So(info.Disks[0].Mount, ShouldEqual, "/")
+		So(info.Disks[0].TotalBytes, ShouldBeGreaterThan, 0)
+		So(info.Disks[0].Error, ShouldBeEmpty)
+		So(info.Disks[1].Error, ShouldNotBeEmpty)
+	})
+
+	Convey("The status has the system block and keeps os_avgload", t, func() {
+		se := NewStandardEndpoints()
+		se.SetDiskMounts("/tmp")
+		status := se.generateStatus()
+		So(status.System.Disks[0].Mount, ShouldEqual, "/tmp")
+		So(status.OSAvgload, ShouldEqual, fmt.Sprintf("%.2f", status.System.LoadAverage.One))
+	})
+}
@@ -0,0 +1,152 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	routing "github.com/go-ozzo/ozzo-routing"
+)
+
+// WarmUpFunc prepares the service to take traffic, e.g. warming caches or running migrations.
+type WarmUpFunc func(ctx context.Context) error
+
+// WarmUpTaskStatus is the progress of a single warm-up task, the state is one of the HealthResult values.
+type WarmUpTaskStatus struct {
+	Name           string     `json:"name"`
+	State          string     `json:"state"` // "not_run", "running", "passed", "failed"
+	Error          string     `json:"error,omitempty"`
+	StartedAt      *time.Time `json:"started_at,omitempty"`
+	DurationMillis float64    `json:"duration_millis"`
+}
+
+// StartupReport is served by /service/startup.
+type StartupReport struct {
+	Complete bool               `json:"complete"` // true once every task has passed
+	Tasks    []WarmUpTaskStatus `json:"tasks"`
+}
+
+type warmUpTask struct {
+	fn     WarmUpFunc
+	status WarmUpTaskStatus
+}
+
+// Register a task that has to complete before GTG succeeds, tasks are run in the order they are added.
+// Its state is included in each health report as "warm-up: <name>", so a failure shows as a failed result.
+func (s *StandardEndpoints) AddWarmUpTask(name string, task WarmUpFunc) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.warmUpTasks = append(s.warmUpTasks, &warmUpTask{fn: task,
+		status: WarmUpTaskStatus{Name: name, State: HealthResultNotRun}})
+}
+
+// RunWarmUp runs each task that hasn't already passed, stopping at the first failure.
+// It can be called again to retry after a failure.
+func (s *StandardEndpoints) RunWarmUp(ctx context.Context) error {
+	s.warmUpLocker.Lock()
+	defer s.warmUpLocker.Unlock()
+
+	s.locker.Lock()
+	tasks := append([]*warmUpTask(nil), s.warmUpTasks...)
+	s.locker.Unlock()
+
+	for _, task := range tasks {
+		s.locker.Lock()
+		passed := task.status.State == HealthResultPassed
+		start := time.Now().UTC()
+		if !passed {
+			task.status = WarmUpTaskStatus{Name: task.status.Name, State: HealthResultRunning, StartedAt: &start}
+		}
+		s.locker.Unlock()
+		if passed {
+			continue
+		}
+
+		err := task.fn(ctx)
+
+		s.locker.Lock()
+		task.status.DurationMillis = DurationToMillis(time.Since(start))
+		task.status.State = HealthResultPassed
+		if err != nil {
+			task.status.State = HealthResultFailed
+			task.status.Error = err.Error()
+		}
+		s.locker.Unlock()
+
+		if err != nil {
+			s.log("warm-up failed", "task", task.status.Name, "error", err.Error())
+			return fmt.Errorf("warm-up %s: %w", task.status.Name, err)
+		}
+		s.log("warm-up complete", "task", task.status.Name)
+	}
+	return nil
+}
+
+// WarmedUp is true once every warm-up task has passed.
+func (s *StandardEndpoints) WarmedUp() bool {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return s.warmedUp()
+}
+
+// must be called with the lock held
+func (s *StandardEndpoints) warmedUp() bool {
+	for _, task := range s.warmUpTasks {
+		if task.status.State != HealthResultPassed {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *StandardEndpoints) StartupReport() StartupReport {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	report := StartupReport{Complete: s.warmedUp(), Tasks: []WarmUpTaskStatus{}}
+	for _, task := range s.warmUpTasks {
+		report.Tasks = append(report.Tasks, task.status)
+	}
+	return report
+}
+
+// the warm-up tasks as health results so failures show up in the health report, must be called with the lock held
+func (s *StandardEndpoints) warmUpResults() []HealthCheckResult {
+	var results []HealthCheckResult
+	for _, task := range s.warmUpTasks {
+		result := HealthCheckResult{Name: "warm-up: " + task.status.Name, Result: task.status.State,
+			DurationMillis: task.status.DurationMillis}
+		if task.status.StartedAt != nil {
+			result.Timestamp = *task.status.StartedAt
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// 200 once warm-up is complete, otherwise 503, with the progress of each task
+func (s *StandardEndpoints) serveStartup(c *routing.Context) error {
+	report := s.StartupReport()
+	if !report.Complete {
+		c.Response.WriteHeader(http.StatusServiceUnavailable)
+	}
+	return c.Write(report)
+}
@@ -0,0 +1,103 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-ozzo/ozzo-routing"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWarmUp(t *testing.T) {
+	r := routing.New()
+	se := NewStandardEndpoints()
+	se.SetLogger(nil)
+	se.RegisterDefaultEndpoints(r)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(res, req)
+		return res
+	}
+
+	migrated := 0
+	cacheErr := errors.New("cache unavailable")
+	se.AddWarmUpTask("migrations", func(ctx context.Context) error {
+		migrated++
+		return nil
+	})
+	se.AddWarmUpTask("cache", func(ctx context.Context) error {
+		return cacheErr
+	})
+
+	Convey("GTG fails until warm-up has run", t, func() {
+		res := serve("/service/healthcheck/gtg")
+		So(res.Code, ShouldEqual, http.StatusServiceUnavailable)
+		So(res.Body.String(), ShouldEqual, "warming up")
+
+		res = serve("/service/startup")
+		So(res.Code, ShouldEqual, http.StatusServiceUnavailable)
+		So(res.Body.String(), ShouldContainSubstring, `"complete":false`)
+		So(res.Body.String(), ShouldContainSubstring, `"state":"not_run"`)
+	})
+
+	Convey("A failed task is reported with its error", t, func() {
+		err := se.RunWarmUp(context.Background())
+		So(errors.Is(err, cacheErr), ShouldBeTrue)
+		So(se.WarmedUp(), ShouldBeFalse)
+
+		report := se.StartupReport()
+		So(report.Tasks[0].State, ShouldEqual, HealthResultPassed)
+		So(report.Tasks[1].State, ShouldEqual, HealthResultFailed)
+		So(report.Tasks[1].Error, ShouldEqual, "cache unavailable")
+		So(serve("/service/healthcheck/gtg").Code, ShouldEqual, http.StatusServiceUnavailable)
+	})
+
+	Convey("Warm-up failures appear in the health report", t, func() {
+		ran := make(chan struct{}, 1)
+		se.SetHealthCheckFuncs(time.Hour, func() HealthCheckResult {
+			defer func() { ran <- struct{}{} }()
+			return HealthCheckResult{Name: "db", Result: HealthResultPassed}
+		})
+		<-ran
+		se.Stop()
+		body := serve("/service/healthcheck").Body.String()
+		So(body, ShouldContainSubstring, `"test_name":"warm-up: cache","test_result":"failed"`)
+		So(body, ShouldContainSubstring, `"test_name":"warm-up: migrations","test_result":"passed"`)
+	})
+
+	Convey("Retrying only runs the tasks that haven't passed", t, func() {
+		se.warmUpTasks[1].fn = func(ctx context.Context) error { return nil }
+		So(se.RunWarmUp(context.Background()), ShouldBeNil)
+		So(migrated, ShouldEqual, 1)
+		So(se.WarmedUp(), ShouldBeTrue)
+		So(serve("/service/healthcheck/gtg").Code, ShouldEqual, http.StatusOK)
+
+		res := serve("/service/startup")
+		So(res.Code, ShouldEqual, http.StatusOK)
+		So(res.Body.String(), ShouldContainSubstring, `"complete":true`)
+	})
+}
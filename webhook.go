@@ -0,0 +1,288 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	WebhookSignatureHeader = "X-SE4-Signature" // "sha256=" followed by the hex HMAC-SHA256 of the body
+	WebhookEventIDHeader   = "X-SE4-Event-ID"  // stays the same across retries, so receivers can deduplicate
+)
+
+var (
+	DefaultWebhookQueueSize  = 100
+	DefaultWebhookMaxRetries = 5
+	DefaultWebhookBackoff    = time.Duration(500) * time.Millisecond
+	DefaultWebhookMaxBackoff = time.Duration(30) * time.Second
+	DefaultWebhookTimeout    = time.Duration(5) * time.Second
+)
+
+// WebhookConfig configures delivery of health transitions to one or more URLs.
+// Zero values are replaced by the defaults above.
+type WebhookConfig struct {
+	URLs       []string
+	Secret     []byte        // if set the payload is signed with HMAC-SHA256
+	QueueSize  int           // the maximum number of undelivered events per URL, further events are dropped
+	MaxRetries int           // the number of retries after the first failed attempt, negative disables retries
+	Backoff    time.Duration // the delay before the first retry, doubled on each subsequent retry
+	MaxBackoff time.Duration // the upper limit of the retry delay
+	Timeout    time.Duration // per attempt timeout, used when Client is nil
+	Client     *http.Client
+}
+
+// WebhookEvent is the JSON payload POSTed to each webhook URL.
+type WebhookEvent struct {
+	ID          string           `json:"id"`
+	Transition  HealthTransition `json:"transition"`
+	MachineName string           `json:"machine_name"`
+	ArtifactID  string           `json:"artifact_id"`
+	Version     string           `json:"version"`
+}
+
+// WebhookNotifier delivers health transitions to webhooks, it is safe for concurrent use.
+type WebhookNotifier struct {
+	config    WebhookConfig
+	status    *Status
+	endpoints []*webhookEndpoint
+
+	locker   *sync.Mutex
+	sequence uint64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type webhookEndpoint struct {
+	url       string
+	queue     chan *webhookDelivery
+	lastState map[string]string // kind/name -> last queued state, used for deduplication, guarded by the notifier lock
+	delivered uint64
+	failed    uint64
+	dropped   uint64
+}
+
+type webhookDelivery struct {
+	id   string
+	body []byte
+}
+
+// WebhookStats counts deliveries for a single URL.
+type WebhookStats struct {
+	URL       string `json:"url"`
+	Delivered uint64 `json:"delivered"`
+	Failed    uint64 `json:"failed"`  // gave up after all retries
+	Dropped   uint64 `json:"dropped"` // the queue was full
+	Queued    int    `json:"queued"`
+}
+
+// NewWebhookNotifier starts a delivery goroutine per URL, call Close to stop them.
+// The status is used to identify the instance in the payload and may be nil.
+func NewWebhookNotifier(config WebhookConfig, status *Status) *WebhookNotifier {
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultWebhookQueueSize
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	} else if config.MaxRetries == 0 {
+		config.MaxRetries = DefaultWebhookMaxRetries
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = DefaultWebhookBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = DefaultWebhookMaxBackoff
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultWebhookTimeout
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: config.Timeout}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &WebhookNotifier{config: config, status: status, locker: &sync.Mutex{}, ctx: ctx, cancel: cancel}
+	for _, url := range config.URLs {
+		ep := &webhookEndpoint{url: url, queue: make(chan *webhookDelivery, config.QueueSize), lastState: map[string]string{}}
+		n.endpoints = append(n.endpoints, ep)
+		n.wg.Add(1)
+		go n.deliverLoop(ep)
+	}
+	return n
+}
+
+// Configure webhooks for health transitions of these endpoints, call Close on the
+// returned notifier to unsubscribe and stop delivery.
+func (s *StandardEndpoints) AddWebhooks(config WebhookConfig) *WebhookNotifier {
+	n := NewWebhookNotifier(config, s.Status)
+	unsubscribe := s.SubscribeHealthTransitions(n.Notify)
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		<-n.ctx.Done()
+		unsubscribe()
+	}()
+	return n
+}
+
+// Notify queues the transition for delivery, it never blocks.
+// A transition to the state that was last queued for the same check & URL is dropped as a duplicate,
+// one dropped because the queue was full is not, so the next one is still delivered.
+// GTG and ASG transitions are only seen when the probes are evaluated, i.e. when their endpoints are called.
+func (n *WebhookNotifier) Notify(t HealthTransition) {
+	key := t.Kind + "/" + t.Name
+	n.locker.Lock()
+	defer n.locker.Unlock()
+	if n.ctx.Err() != nil {
+		return
+	}
+	var endpoints []*webhookEndpoint
+	for _, ep := range n.endpoints {
+		if ep.lastState[key] != t.Current {
+			endpoints = append(endpoints, ep)
+		}
+	}
+	if len(endpoints) == 0 {
+		return
+	}
+	n.sequence++
+	event := WebhookEvent{ID: fmt.Sprintf("%d-%d", t.Timestamp.UnixNano(), n.sequence), Transition: t}
+
+	if n.status != nil {
+		event.MachineName = n.status.MachineName
+		event.ArtifactID = n.status.ArtifactID
+		event.Version = n.status.Version
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	d := &webhookDelivery{id: event.ID, body: body}
+	for _, ep := range endpoints {
+		select {
+		case ep.queue <- d:
+			ep.lastState[key] = t.Current
+		default:
+			atomic.AddUint64(&ep.dropped, 1)
+		}
+	}
+}
+
+// Stats returns the delivery counters for each URL.
+func (n *WebhookNotifier) Stats() []WebhookStats {
+	stats := make([]WebhookStats, 0, len(n.endpoints))
+	for _, ep := range n.endpoints {
+		stats = append(stats, WebhookStats{URL: ep.url,
+			Delivered: atomic.LoadUint64(&ep.delivered),
+			Failed:    atomic.LoadUint64(&ep.failed),
+			Dropped:   atomic.LoadUint64(&ep.dropped),
+			Queued:    len(ep.queue)})
+	}
+	return stats
+}
+
+// Close stops delivery, abandoning any queued events, and waits for the delivery goroutines to exit.
+func (n *WebhookNotifier) Close() {
+	n.locker.Lock()
+	n.cancel()
+	n.locker.Unlock()
+	n.wg.Wait()
+}
+
+func (n *WebhookNotifier) deliverLoop(ep *webhookEndpoint) {
+	defer n.wg.Done()
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case d := <-ep.queue:
+			if n.deliver(ep.url, d) {
+				atomic.AddUint64(&ep.delivered, 1)
+			} else if n.ctx.Err() == nil {
+				atomic.AddUint64(&ep.failed, 1)
+			}
+		}
+	}
+}
+
+// attempt delivery with exponential backoff, returns true once the receiver responds with a 2XX
+func (n *WebhookNotifier) deliver(url string, d *webhookDelivery) bool {
+	backoff := n.config.Backoff
+	for attempt := 0; attempt <= n.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-n.ctx.Done():
+				return false
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > n.config.MaxBackoff {
+				backoff = n.config.MaxBackoff
+			}
+		}
+		if n.post(url, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// the most of a response body read before closing it, a larger one costs the connection instead
+const webhookDrainLimit = 1 << 20
+
+func (n *WebhookNotifier) post(url string, d *webhookDelivery) bool {
+	req, err := http.NewRequestWithContext(n.ctx, http.MethodPost, url, bytes.NewReader(d.body))
+	if err != nil {
+		return false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventIDHeader, d.id)
+	if len(n.config.Secret) > 0 {
+		req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(n.config.Secret, d.body))
+	}
+	resp, err := n.config.Client.Do(req)
+	if err != nil {
+		return false
+	}
+	// read what's left of a small body so the connection is reused for the next delivery
+	io.Copy(io.Discard, io.LimitReader(resp.Body, webhookDrainLimit))
+	resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
+
+// SignWebhookPayload returns the value of the signature header for the body, receivers
+// should compute the same and compare with hmac.Equal.
+func SignWebhookPayload(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
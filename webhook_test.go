@@ -0,0 +1,188 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+package se4
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWebhookDelivery(t *testing.T) {
+	secret := []byte("s3cret")
+	var mu sync.Mutex
+	var events []WebhookEvent
+	var ids []string
+	attempts := 0
+	received := make(chan struct{}, 10)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError) // force a retry
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(WebhookSignatureHeader) != SignWebhookPayload(secret, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var event WebhookEvent
+		json.Unmarshal(body, &event)
+		events = append(events, event)
+		ids = append(ids, r.Header.Get(WebhookEventIDHeader))
+		received <- struct{}{}
+	}))
+	defer receiver.Close()
+
+	se := NewStandardEndpoints()
+	se.SetLogger(nil)
+	n := se.AddWebhooks(WebhookConfig{URLs: []string{receiver.URL}, Secret: secret, Backoff: time.Millisecond})
+	defer n.Close()
+
+	transition := HealthTransition{Kind: HealthTransitionGoodToGo, Name: HealthTransitionGoodToGo,
+		Previous: HealthResultPassed, Current: HealthResultFailed, Timestamp: time.Now()}
+	n.Notify(transition)
+	n.Notify(transition) // duplicate
+
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+	}
+	for i := 0; i < 100 && n.Stats()[0].Delivered == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	Convey("Signed event is delivered after a retry", t, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		So(attempts, ShouldEqual, 2)
+		So(len(events), ShouldEqual, 1)
+		So(events[0].ID, ShouldEqual, ids[0])
+		So(events[0].Transition.Current, ShouldEqual, HealthResultFailed)
+		So(events[0].Version, ShouldEqual, "dev")
+		So(n.Stats()[0].Delivered, ShouldEqual, 1)
+	})
+
+	Convey("GTG probe transitions reach the webhook", t, func() {
+		se.SetGoodToGoFunc(func() bool { return true })
+		se.probeTransition(HealthTransitionGoodToGo, true)
+
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		So(len(events), ShouldEqual, 2)
+		So(events[1].Transition.Kind, ShouldEqual, HealthTransitionGoodToGo)
+		So(events[1].Transition.Current, ShouldEqual, HealthResultPassed)
+	})
+}
+
+func TestWebhookBoundedQueue(t *testing.T) {
+	block := make(chan struct{})
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer receiver.Close()
+	defer close(block)
+
+	n := NewWebhookNotifier(WebhookConfig{URLs: []string{receiver.URL}, QueueSize: 2, MaxRetries: -1}, nil)
+	defer n.Close()
+
+	for i := 0; i < 10; i++ {
+		n.Notify(HealthTransition{Kind: HealthTransitionCheck, Name: fmt.Sprintf("db%d", i), Current: HealthResultFailed, Timestamp: time.Now()})
+	}
+
+	Convey("Events beyond the queue size are dropped", t, func() {
+		stats := n.Stats()[0]
+		So(stats.Queued, ShouldBeLessThanOrEqualTo, 2)
+		So(stats.Dropped, ShouldBeGreaterThanOrEqualTo, 7)
+	})
+}
+
+func TestWebhookDroppedEventIsNotADuplicate(t *testing.T) {
+	block := make(chan struct{})
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer receiver.Close()
+	defer close(block)
+
+	n := NewWebhookNotifier(WebhookConfig{URLs: []string{receiver.URL}, QueueSize: 1, MaxRetries: -1}, nil)
+	defer n.Close()
+	notify := func(state string) {
+		n.Notify(HealthTransition{Kind: HealthTransitionCheck, Name: "db", Current: state, Timestamp: time.Now()})
+	}
+
+	notify(HealthResultFailed)
+	for n.Stats()[0].Queued != 0 { // the worker is now blocked delivering it
+		time.Sleep(time.Millisecond)
+	}
+	notify(HealthResultPassed) // fills the queue
+	notify(HealthResultFailed) // dropped
+	notify(HealthResultFailed) // not a duplicate of the dropped event, so dropped again rather than ignored
+
+	Convey("A transition dropped from a full queue doesn't suppress the next one", t, func() {
+		So(n.Stats()[0].Dropped, ShouldEqual, 2)
+	})
+}
+
+func TestWebhookReusesConnections(t *testing.T) {
+	receiver := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 512<<10)) // more than the transport drains by itself on close
+	}))
+	var mu sync.Mutex
+	connections := 0
+	receiver.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			connections++
+			mu.Unlock()
+		}
+	}
+	receiver.Start()
+	defer receiver.Close()
+
+	n := NewWebhookNotifier(WebhookConfig{URLs: []string{receiver.URL}}, nil)
+	defer n.Close()
+	for i := 0; i < 5; i++ {
+		n.Notify(HealthTransition{Kind: HealthTransitionCheck, Name: fmt.Sprintf("db%d", i), Current: HealthResultFailed, Timestamp: time.Now()})
+	}
+	for n.Stats()[0].Delivered < 5 {
+		time.Sleep(time.Millisecond)
+	}
+
+	Convey("Deliveries share a keep-alive connection", t, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		So(connections, ShouldEqual, 1)
+	})
+}